@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+	"github.com/Danvs60/snippetbox/internal/validator"
+)
+
+// Everything the commands need, so that tests can swap the
+// output, the clock and the password prompt
+type ctl struct {
+	users        *models.UserModel
+	snippets     *models.SnippetModel
+	sessions     *models.SessionModel
+	stats        *models.StatsModel
+	config       config
+	out          io.Writer // command output
+	errOut       io.Writer // usage and flag errors
+	now          func() time.Time
+	readPassword func(prompt string) (string, error)
+}
+
+// Run the command named by the first argument
+func (c *ctl) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch {
+	case args[0] == "user" && len(args) > 1 && args[1] == "create":
+		return c.userCreate(ctx, args[2:])
+	case args[0] == "user" && len(args) > 1 && args[1] == "reset-password":
+		return c.userResetPassword(ctx, args[2:])
+	case args[0] == "snippet" && len(args) > 1 && args[1] == "list":
+		return c.snippetList(ctx, args[2:])
+	case args[0] == "snippet" && len(args) > 1 && args[1] == "delete":
+		return c.snippetDelete(ctx, args[2:])
+	case args[0] == "purge" && len(args) == 1:
+		return c.purge(ctx)
+	case args[0] == "stats" && len(args) == 1:
+		return c.printStats(ctx)
+	default:
+		return errUsage
+	}
+}
+
+// flag set of a subcommand, printing its usage and errors to errOut
+func (c *ctl) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("snippetctl "+name, flag.ContinueOnError)
+	fs.SetOutput(c.errOut)
+	return fs
+}
+
+// Create a user, with the same rules as the signup form
+func (c *ctl) userCreate(ctx context.Context, args []string) error {
+	var name, email string
+
+	fs := c.flagSet("user create")
+	fs.StringVar(&name, "name", "", "Name of the user")
+	fs.StringVar(&email, "email", "", "Email address the user logs in with")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+
+	password, err := c.readPassword("Password: ")
+	if err != nil {
+		return err
+	}
+
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(name), "name", "This field cannot be blank")
+	v.CheckField(validator.NotBlank(email), "email", "This field cannot be blank")
+	v.CheckField(validator.Matches(email, validator.EmailRX), "email", "This field must be a valid email address")
+	v.CheckField(validator.NotBlank(password), "password", "This field cannot be blank")
+	v.CheckField(validator.MinChars(password, 8), "password", "This field must be at least 8 characters long")
+	if !v.Valid() {
+		return fieldErrors(v)
+	}
+
+	err = c.users.Insert(ctx, name, email, password)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			return fmt.Errorf("email %s is already in use", email)
+		}
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Created user %s <%s>\n", name, email)
+	return nil
+}
+
+// Replace the password of a user
+func (c *ctl) userResetPassword(ctx context.Context, args []string) error {
+	var email string
+
+	fs := c.flagSet("user reset-password")
+	fs.StringVar(&email, "email", "", "Email address of the user")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 || email == "" {
+		return errUsage
+	}
+
+	password, err := c.readPassword("New password: ")
+	if err != nil {
+		return err
+	}
+
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(password), "password", "This field cannot be blank")
+	v.CheckField(validator.MinChars(password, 8), "password", "This field must be at least 8 characters long")
+	if !v.Valid() {
+		return fieldErrors(v)
+	}
+
+	err = c.users.SetPassword(ctx, email, password)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return fmt.Errorf("no user with email %s", email)
+		}
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Reset the password of %s\n", email)
+	return nil
+}
+
+// List every snippet, or those of a user, newest first.
+// Includes private, view-limited and expired snippets
+func (c *ctl) snippetList(ctx context.Context, args []string) error {
+	var userID int
+	filters := models.Filters{}
+
+	fs := c.flagSet("snippet list")
+	fs.IntVar(&userID, "user", 0, "Only list the snippets of the user with this ID")
+	fs.IntVar(&filters.Page, "page", 1, "Page to list")
+	fs.IntVar(&filters.PageSize, "size", 20, "Snippets per page")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+	// same bounds as the ?page= and ?size= query parameters
+	if filters.Page < 1 || filters.Page > 10_000_000 {
+		return fmt.Errorf("page must be between 1 and 10000000, got %d", filters.Page)
+	}
+	if filters.PageSize < 1 || filters.PageSize > 100 {
+		return fmt.Errorf("size must be between 1 and 100, got %d", filters.PageSize)
+	}
+
+	var snippets []*models.Snippet
+	var metadata models.Metadata
+	if userID != 0 {
+		snippets, metadata, err = c.snippets.ListByUser(ctx, userID, filters)
+	} else {
+		snippets, metadata, err = c.snippets.ListAll(ctx, filters)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(snippets) == 0 {
+		fmt.Fprintln(c.out, "No snippets")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSLUG\tUSER\tVISIBILITY\tCREATED\tEXPIRES\tTITLE")
+	for _, s := range snippets {
+		expires := "never"
+		if s.Expires != nil {
+			expires = formatTime(*s.Expires)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\t%s\n", s.ID, s.URLSlug(), s.UserID, s.Visibility, formatTime(s.Created), expires, truncate(s.Title, 40))
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Page %d of %d, %d snippets\n", metadata.CurrentPage, metadata.LastPage, metadata.TotalRecords)
+	return nil
+}
+
+// Delete snippets by ID, along with their revisions.
+// Stops at the first ID that can't be deleted
+func (c *ctl) snippetDelete(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	ids := make([]int, len(args))
+	for i, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id < 1 {
+			return fmt.Errorf("invalid snippet ID %q", arg)
+		}
+		ids[i] = id
+	}
+
+	for _, id := range ids {
+		err := c.snippets.Delete(ctx, id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				return fmt.Errorf("no snippet with ID %d", id)
+			}
+			return err
+		}
+
+		fmt.Fprintf(c.out, "Deleted snippet %d\n", id)
+	}
+
+	return nil
+}
+
+// Delete expired snippets and sessions once, like the purger of
+// the web server does in the background
+func (c *ctl) purge(ctx context.Context) error {
+	cutoff := c.now().Add(-c.config.purgeGrace)
+
+	jobs := []struct {
+		name  string
+		purge models.PurgeFunc
+	}{
+		{name: "snippets", purge: c.snippets.PurgeExpired},
+		{name: "sessions", purge: c.sessions.PurgeExpired},
+	}
+
+	for _, job := range jobs {
+		total, err := models.PurgeBatches(ctx, job.purge, cutoff, c.config.purgeBatch)
+		if err != nil {
+			return fmt.Errorf("purging expired %s: %w", job.name, err)
+		}
+
+		fmt.Fprintf(c.out, "Purged %d expired %s\n", total, job.name)
+	}
+
+	return nil
+}
+
+// Print the row counts of the instance
+func (c *ctl) printStats(ctx context.Context) error {
+	stats, err := c.stats.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Users\t%d\n", stats.Users)
+	fmt.Fprintf(tw, "Snippets\t%d\n", stats.Snippets)
+	fmt.Fprintf(tw, "  public\t%d\n", stats.PublicSnippets)
+	fmt.Fprintf(tw, "  unlisted\t%d\n", stats.UnlistedSnippets)
+	fmt.Fprintf(tw, "  private\t%d\n", stats.PrivateSnippets)
+	fmt.Fprintf(tw, "  view-limited\t%d\n", stats.ViewLimitedSnippets)
+	fmt.Fprintf(tw, "  expired\t%d\n", stats.ExpiredSnippets)
+	fmt.Fprintf(tw, "Revisions\t%d\n", stats.Revisions)
+	fmt.Fprintf(tw, "API tokens\t%d\n", stats.Tokens)
+	fmt.Fprintf(tw, "Sessions\t%d\n", stats.Sessions)
+
+	return tw.Flush()
+}
+
+// join the field errors of a failed validation into one error
+func fieldErrors(v validator.Validator) error {
+	var errs []error
+	// fixed order, maps don't have one
+	for _, field := range []string{"name", "email", "password"} {
+		if msg, ok := v.FieldErrors[field]; ok {
+			errs = append(errs, fmt.Errorf("%s: %s", field, msg))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+// shorten s to n characters, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/migrations"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+// ctl on a migrated SQLite database, reading password as every password
+func newTestCtl(t *testing.T, password string) (*ctl, *bytes.Buffer) {
+	db, err := models.Open(models.DriverSQLite, "file:"+filepath.Join(t.TempDir(), "snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.New(db, models.DriverSQLite, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultConfig()
+	cfg.dbDriver = models.DriverSQLite
+	cfg.purgeBatch = 2
+
+	var out bytes.Buffer
+
+	return &ctl{
+		users:    &models.UserModel{DB: db, Driver: cfg.dbDriver, BcryptCost: 4},
+		snippets: &models.SnippetModel{DB: db, Driver: cfg.dbDriver},
+		sessions: &models.SessionModel{DB: db, Driver: cfg.dbDriver},
+		stats:    &models.StatsModel{DB: db, Driver: cfg.dbDriver},
+		config:   cfg,
+		out:      &out,
+		errOut:   io.Discard,
+		now:      time.Now,
+		readPassword: func(string) (string, error) {
+			return password, nil
+		},
+	}, &out
+}
+
+func TestRunUsage(t *testing.T) {
+	c, _ := newTestCtl(t, "")
+
+	for _, args := range [][]string{nil, {"user"}, {"user", "delete"}, {"snippet", "delete"}, {"stats", "now"}} {
+		err := c.run(context.Background(), args)
+		assert.Equal(t, errors.Is(err, errUsage), true)
+	}
+}
+
+func TestUserCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		password string
+		wantErr  string
+	}{
+		{
+			name:     "Valid",
+			args:     []string{"-name", "Alice", "-email", "alice@example.com"},
+			password: "pa$$word",
+		},
+		{
+			name:     "Duplicate email",
+			args:     []string{"-name", "Alice", "-email", "alice@example.com"},
+			password: "pa$$word",
+			wantErr:  "email alice@example.com is already in use",
+		},
+		{
+			name:     "Invalid",
+			args:     []string{"-email", "alice@"},
+			password: "pa$$",
+			wantErr:  "name: This field cannot be blank\nemail: This field must be a valid email address\npassword: This field must be at least 8 characters long",
+		},
+	}
+
+	c, out := newTestCtl(t, "")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			c.readPassword = func(string) (string, error) {
+				return tt.password, nil
+			}
+
+			err := c.run(context.Background(), append([]string{"user", "create"}, tt.args...))
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				assert.Equal(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, err, nil)
+			assert.Equal(t, out.String(), "Created user Alice <alice@example.com>\n")
+		})
+	}
+}
+
+func TestUserResetPassword(t *testing.T) {
+	c, out := newTestCtl(t, "new pa$$word")
+
+	err := c.users.Insert(context.Background(), "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.run(context.Background(), []string{"user", "reset-password", "-email", "alice@example.com"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, out.String(), "Reset the password of alice@example.com\n")
+
+	_, err = c.users.Authenticate(context.Background(), "alice@example.com", "new pa$$word")
+	assert.Equal(t, err, nil)
+
+	err = c.run(context.Background(), []string{"user", "reset-password", "-email", "bob@example.com"})
+	assert.Equal(t, err.Error(), "no user with email bob@example.com")
+
+	err = c.run(context.Background(), []string{"user", "reset-password"})
+	assert.Equal(t, errors.Is(err, errUsage), true)
+}
+
+func TestSnippetListAndDelete(t *testing.T) {
+	c, out := newTestCtl(t, "")
+
+	err := c.users.Insert(context.Background(), "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.users.Insert(context.Background(), "Bob", "bob@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]int, 3)
+	for i, userID := range []int{1, 1, 2} {
+		ids[i], err = c.snippets.Insert(context.Background(), userID, "Snippet", "content", "plaintext", models.VisibilityPrivate, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// private snippets are listed too
+	err = c.run(context.Background(), []string{"snippet", "list", "-size", "2"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Contains(out.String(), "Page 1 of 2, 3 snippets"), true)
+
+	out.Reset()
+	err = c.run(context.Background(), []string{"snippet", "list", "-user", "2"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Contains(out.String(), "Page 1 of 1, 1 snippets"), true)
+
+	err = c.run(context.Background(), []string{"snippet", "list", "-size", "101"})
+	assert.Equal(t, err != nil, true)
+
+	out.Reset()
+	err = c.run(context.Background(), []string{"snippet", "delete", "1", "2"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, out.String(), "Deleted snippet 1\nDeleted snippet 2\n")
+
+	err = c.run(context.Background(), []string{"snippet", "delete", "2"})
+	assert.Equal(t, err.Error(), "no snippet with ID 2")
+
+	err = c.run(context.Background(), []string{"snippet", "delete", "two"})
+	assert.Equal(t, err.Error(), `invalid snippet ID "two"`)
+
+	out.Reset()
+	err = c.run(context.Background(), []string{"snippet", "list"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Contains(out.String(), "Page 1 of 1, 1 snippets"), true)
+}
+
+func TestPurgeAndStats(t *testing.T) {
+	c, out := newTestCtl(t, "")
+
+	err := c.users.Insert(context.Background(), "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	for range 3 {
+		_, err = c.snippets.Insert(context.Background(), 1, "Expiring", "content", "plaintext", models.VisibilityPublic, &expires, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = c.snippets.Insert(context.Background(), 1, "Forever", "content", "plaintext", models.VisibilityPublic, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.run(context.Background(), []string{"stats"})
+	assert.Equal(t, err, nil)
+	// ignore the column alignment
+	fields := strings.Join(strings.Fields(out.String()), " ")
+	assert.Equal(t, strings.Contains(fields, "Users 1 Snippets 4 public 4 unlisted 0"), true)
+
+	// nothing has expired yet
+	out.Reset()
+	err = c.run(context.Background(), []string{"purge"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, out.String(), "Purged 0 expired snippets\nPurged 0 expired sessions\n")
+
+	// past the expiry and the grace period, in more than one batch
+	c.now = func() time.Time {
+		return time.Now().Add(time.Hour + c.config.purgeGrace + time.Minute)
+	}
+
+	out.Reset()
+	err = c.run(context.Background(), []string{"purge"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, out.String(), "Purged 3 expired snippets\nPurged 0 expired sessions\n")
+
+	stats, err := c.stats.Get(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, stats.Snippets, 1)
+}
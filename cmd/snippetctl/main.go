@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+	"github.com/Danvs60/snippetbox/internal/settings"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+const usage = `Operate a snippetbox instance.
+
+Usage:
+  snippetctl [flags] user create -name NAME -email EMAIL
+  snippetctl [flags] user reset-password -email EMAIL
+  snippetctl [flags] snippet list [-user ID] [-page N] [-size N]
+  snippetctl [flags] snippet delete ID...
+  snippetctl [flags] purge
+  snippetctl [flags] stats
+
+Passwords are prompted for, or read from the first line of stdin
+when it's not a terminal.
+
+Flags:
+`
+
+var errUsage = errors.New("invalid command, see snippetctl -h")
+
+// Settings of snippetctl, the ones it shares with the web server.
+// They're read the same way (see internal/settings) and config files
+// written for the web server are accepted, its other settings ignored
+type config struct {
+	dbDriver   string
+	dsn        string
+	bcryptCost int
+	purgeGrace time.Duration
+	purgeBatch int
+}
+
+// same defaults as the web server
+func defaultConfig() config {
+	return config{
+		dbDriver:   models.DriverMySQL,
+		dsn:        "web:st0ngb00ze@/snippetbox?parseTime=true",
+		bcryptCost: models.DefaultBcryptCost,
+		purgeGrace: 24 * time.Hour,
+		purgeBatch: 500,
+	}
+}
+
+// Load the config from the defaults, the config file, the environment
+// (read with lookupEnv) and the command-line arguments, then validate it.
+// Also returns the arguments left after the flags, the command to run.
+// Usage and flag errors are written to output
+func loadConfig(args []string, lookupEnv func(string) (string, bool), output io.Writer) (config, []string, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("snippetctl", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprint(output, usage)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&cfg.dbDriver, "db-driver", cfg.dbDriver, "Database backend: "+strings.Join(models.Drivers, ", "))
+	fs.StringVar(&cfg.dsn, "dsn", cfg.dsn, "Data source name, in the format of the -db-driver backend")
+	fs.IntVar(&cfg.bcryptCost, "bcrypt-cost", cfg.bcryptCost, "bcrypt cost of new password hashes")
+	fs.DurationVar(&cfg.purgeGrace, "purge-grace", cfg.purgeGrace, "How long expired snippets and sessions are kept before being purged")
+	fs.IntVar(&cfg.purgeBatch, "purge-batch", cfg.purgeBatch, "Maximum rows deleted per purge statement")
+
+	err := settings.Load(fs, args, lookupEnv, true)
+	if err != nil {
+		return config{}, nil, err
+	}
+
+	return cfg, fs.Args(), cfg.validate()
+}
+
+// report every invalid setting at once
+func (cfg config) validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(slices.Contains(models.Drivers, cfg.dbDriver), "db-driver must be one of %s, got %q", strings.Join(models.Drivers, ", "), cfg.dbDriver)
+	check(cfg.dsn != "", "dsn must not be empty")
+	check(cfg.bcryptCost >= bcrypt.MinCost && cfg.bcryptCost <= bcrypt.MaxCost, "bcrypt-cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cfg.bcryptCost)
+	check(cfg.purgeGrace >= 0, "purge-grace must not be negative, got %s", cfg.purgeGrace)
+	check(cfg.purgeBatch > 0, "purge-batch must be positive, got %d", cfg.purgeBatch)
+
+	return errors.Join(errs...)
+}
+
+func main() {
+	cfg, args, err := loadConfig(os.Args[1:], os.LookupEnv, os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fail(err)
+	}
+
+	if len(args) == 0 {
+		fail(errUsage)
+	}
+
+	db, err := models.Open(cfg.dbDriver, cfg.dsn)
+	if err != nil {
+		fail(err)
+	}
+
+	c := &ctl{
+		users:        &models.UserModel{DB: db, Driver: cfg.dbDriver, BcryptCost: cfg.bcryptCost},
+		snippets:     &models.SnippetModel{DB: db, Driver: cfg.dbDriver},
+		sessions:     &models.SessionModel{DB: db, Driver: cfg.dbDriver},
+		stats:        &models.StatsModel{DB: db, Driver: cfg.dbDriver},
+		config:       cfg,
+		out:          os.Stdout,
+		errOut:       os.Stderr,
+		now:          time.Now,
+		readPassword: readPassword,
+	}
+
+	// Ctrl+C cancels the query in progress,
+	// purge stops once the current batch is done
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err = c.run(ctx, args)
+	stop()
+	db.Close()
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "snippetctl: %s\n", err)
+	os.Exit(1)
+}
+
+// Read a password from the terminal without echoing it, or from the first
+// line of stdin when it's not a terminal, so that scripts can pipe it in
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
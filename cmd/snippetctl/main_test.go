@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+)
+
+// lookupEnv backed by a map
+func fakeEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestLoadConfigSharedWithWeb(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	// addr and session_lifetime only mean something to the web server
+	err := os.WriteFile(path, []byte(`{
+		"addr": ":5000",
+		"session_lifetime": "1h",
+		"db_driver": "sqlite",
+		"dsn": "file:snippetbox.db",
+		"purge_grace": "2h"
+	}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"SNIPPETBOX_CONFIG":      path,
+		"SNIPPETBOX_PURGE_BATCH": "50",
+	}
+
+	cfg, args, err := loadConfig([]string{"stats"}, fakeEnv(env), io.Discard)
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cfg.dbDriver, "sqlite")
+	assert.Equal(t, cfg.dsn, "file:snippetbox.db")
+	assert.Equal(t, cfg.purgeGrace, 2*time.Hour)
+	assert.Equal(t, cfg.purgeBatch, 50)
+	assert.Equal(t, cfg.bcryptCost, defaultConfig().bcryptCost)
+	assert.Equal(t, len(args), 1)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "Unknown flag", args: []string{"-addr", ":5000"}},
+		{name: "Unknown database driver", args: []string{"-db-driver", "oracle"}},
+		{name: "Empty DSN", args: []string{"-dsn", ""}},
+		{name: "Zero purge batch", args: []string{"-purge-batch", "0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := loadConfig(tt.args, fakeEnv(nil), io.Discard)
+			assert.Equal(t, err != nil, true)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// values of -access-log-format
+const (
+	accessLogCommon   = "common"   // Common Log Format, followed by the request ID
+	accessLogCombined = "combined" // Common Log Format with referer and user agent, followed by the request ID
+	accessLogJSON     = "json"     // one JSON object per line, with duration and request ID
+)
+
+var accessLogFormats = []string{accessLogCommon, accessLogCombined, accessLogJSON}
+
+// Writes a line per completed request, see logRequest
+type accessLog struct {
+	mu      sync.Mutex // serialises the text lines
+	out     io.Writer
+	json    *slog.Logger // used by the json format
+	format  string
+	sample  float64          // fraction of the requests logged
+	exclude []string         // paths not logged, prefixes when ending with /
+	random  func() float64   // in [0, 1), replaced in tests
+	now     func() time.Time // clock, replaced in tests
+}
+
+// Access log writing format lines to out. exclude is a comma separated list
+// of paths, those ending with a slash exclude every path below them
+func newAccessLog(out io.Writer, format string, sample float64, exclude string) *accessLog {
+	var paths []string
+	for _, path := range strings.Split(exclude, ",") {
+		path = strings.TrimSpace(path)
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return &accessLog{
+		out:     out,
+		json:    newLogger(out, logFormatJSON),
+		format:  format,
+		sample:  sample,
+		exclude: paths,
+		random:  rand.Float64,
+		now:     time.Now,
+	}
+}
+
+// report whether requests for path are left out of the log
+func (l *accessLog) excluded(path string) bool {
+	for _, p := range l.exclude {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// report whether a request answered with status is picked by the sampling,
+// server errors always are
+func (l *accessLog) sampled(status int) bool {
+	return status >= 500 || l.sample >= 1 || l.random() < l.sample
+}
+
+// Log a completed request that started at start
+func (l *accessLog) write(r *http.Request, rec *responseRecorder, start time.Time) {
+	duration := l.now().Sub(start)
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if l.format == accessLogJSON {
+		l.json.InfoContext(r.Context(), "request",
+			"remote_addr", host,
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"proto", r.Proto,
+			"status", rec.status(),
+			"size", rec.size,
+			"duration_ms", float64(duration.Microseconds())/1000,
+			"referer", r.Referer(),
+			"user_agent", r.UserAgent(),
+		)
+		return
+	}
+
+	// - for no body, as in the Common Log Format
+	size := "-"
+	if rec.size > 0 {
+		size = fmt.Sprint(rec.size)
+	}
+
+	// quoted with Go escapes, so that a request line can't forge log lines
+	line := fmt.Sprintf("%s - - [%s] %q %d %s", host, start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+r.URL.RequestURI()+" "+r.Proto, rec.status(), size)
+	if l.format == accessLogCombined {
+		line += fmt.Sprintf(" %q %q", orDash(r.Referer()), orDash(r.UserAgent()))
+	}
+
+	// trailing field, so that parsers of the standard formats still read the
+	// line. requestID only accepts IDs that need no quoting
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	line += " " + orDash(id)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.out, line)
+}
+
+// - stands for missing values in the Combined Log Format
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// http.ResponseWriter remembering the status code and
+// the number of body bytes written
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+	size int
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += n
+	return n, err
+}
+
+// lets http.ResponseController reach Flush and friends
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// status code of the response, 200 if the handler wrote nothing
+func (rec *responseRecorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+	return rec.code
+}
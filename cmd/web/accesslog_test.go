@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+)
+
+// serve one request through logRequest with an access log writing
+// format to a buffer. The handler answers with status and body
+// and takes 1.5ms on the access log clock
+func logOneRequest(t *testing.T, l *accessLog, r *http.Request, status int, body string) string {
+	var out bytes.Buffer
+	l.out = &out
+	l.json = newLogger(&out, logFormatJSON)
+
+	start := time.Date(2024, 3, 17, 10, 15, 0, 0, time.FixedZone("CET", 3600))
+	l.now = func() time.Time { return start }
+
+	app := newTestApplication(t)
+	app.accessLog = l
+
+	handler := app.logRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.now = func() time.Time { return start.Add(1500 * time.Microsecond) }
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	return out.String()
+}
+
+func TestAccessLogFormats(t *testing.T) {
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, `/s/abc?q="x"`, nil)
+		r.RemoteAddr = "192.0.2.1:51234"
+		r.Header.Set("Referer", "https://example.com/")
+		r.Header.Set("User-Agent", "curl/8.0")
+		// as set by the requestID middleware
+		return r.WithContext(context.WithValue(r.Context(), requestIDContextKey, "req-42"))
+	}
+
+	t.Run("Common", func(t *testing.T) {
+		line := logOneRequest(t, newAccessLog(nil, accessLogCommon, 1, ""), newRequest(), http.StatusCreated, "hello")
+		assert.Equal(t, line, `192.0.2.1 - - [17/Mar/2024:10:15:00 +0100] "GET /s/abc?q=\"x\" HTTP/1.1" 201 5 req-42`+"\n")
+	})
+
+	t.Run("Combined", func(t *testing.T) {
+		line := logOneRequest(t, newAccessLog(nil, accessLogCombined, 1, ""), newRequest(), http.StatusNoContent, "")
+		assert.Equal(t, line, `192.0.2.1 - - [17/Mar/2024:10:15:00 +0100] "GET /s/abc?q=\"x\" HTTP/1.1" 204 - "https://example.com/" "curl/8.0" req-42`+"\n")
+
+		r := newRequest()
+		r.Header.Del("Referer")
+		line = logOneRequest(t, newAccessLog(nil, accessLogCombined, 1, ""), r, http.StatusOK, "")
+		assert.Equal(t, strings.HasSuffix(line, ` 200 - "-" "curl/8.0" req-42`+"\n"), true)
+	})
+
+	t.Run("No request ID", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:51234"
+
+		line := logOneRequest(t, newAccessLog(nil, accessLogCommon, 1, ""), r, http.StatusOK, "")
+		assert.Equal(t, line, `192.0.2.1 - - [17/Mar/2024:10:15:00 +0100] "GET / HTTP/1.1" 200 - -`+"\n")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		line := logOneRequest(t, newAccessLog(nil, accessLogJSON, 1, ""), newRequest(), http.StatusOK, "hello")
+
+		var record map[string]any
+		err := json.Unmarshal([]byte(line), &record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, record["remote_addr"], any("192.0.2.1"))
+		assert.Equal(t, record["uri"], any(`/s/abc?q="x"`))
+		assert.Equal(t, record["status"], any(float64(200)))
+		assert.Equal(t, record["size"], any(float64(5)))
+		assert.Equal(t, record["duration_ms"], any(1.5))
+		assert.Equal(t, record["user_agent"], any("curl/8.0"))
+		assert.Equal(t, record["request_id"], any("req-42"))
+	})
+}
+
+func TestAccessLogExclude(t *testing.T) {
+	l := newAccessLog(nil, accessLogCommon, 1, " /static/, /ping ,")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/static/css/main.css", want: true},
+		{path: "/static/", want: true},
+		{path: "/ping", want: true},
+		{path: "/ping/more", want: false},
+		{path: "/pings", want: false},
+		{path: "/static", want: false},
+		{path: "/", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, l.excluded(tt.path), tt.want)
+		})
+	}
+
+	line := logOneRequest(t, l, httptest.NewRequest(http.MethodGet, "/ping", nil), http.StatusOK, "OK")
+	assert.Equal(t, line, "")
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample float64
+		random float64
+		status int
+		want   bool
+	}{
+		{name: "Everything", sample: 1, random: 0.99, status: http.StatusOK, want: true},
+		{name: "Picked", sample: 0.5, random: 0.2, status: http.StatusOK, want: true},
+		{name: "Left out", sample: 0.5, random: 0.7, status: http.StatusOK, want: false},
+		{name: "Nothing", sample: 0, random: 0, status: http.StatusNotFound, want: false},
+		{name: "Server error", sample: 0, random: 0.7, status: http.StatusInternalServerError, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newAccessLog(nil, accessLogCommon, tt.sample, "")
+			l.random = func() float64 { return tt.random }
+
+			line := logOneRequest(t, l, httptest.NewRequest(http.MethodGet, "/", nil), tt.status, "")
+			assert.Equal(t, line != "", tt.want)
+			if tt.want {
+				assert.Equal(t, strings.Contains(line, `"GET / HTTP/1.1"`), true)
+			}
+		})
+	}
+}
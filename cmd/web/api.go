@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+	"github.com/Danvs60/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// envelope wraps JSON responses so that the top level is always an object
+type envelope map[string]any
+
+// Problem details body (RFC 9457) used for every API error response
+type problem struct {
+	Type   string            `json:"type"`
+	Title  string            `json:"title"`
+	Status int               `json:"status"`
+	Detail string            `json:"detail,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// returned by readJSON when the request body is not sent as JSON
+var errUnsupportedMediaType = errors.New("body must be sent with Content-Type application/json")
+
+// write data as JSON with the given status code
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// decode a JSON request body into dst
+// errors are safe to show to the client
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	// only accept JSON bodies. Cross-site HTML forms cannot set this
+	// content type, which is what keeps the cookie-authenticated API
+	// safe without a CSRF token
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+
+	// limit request body to 1MB
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return fmt.Errorf("body contains unknown key %s", fieldName)
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	// body must only contain a single JSON value
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// write an application/problem+json error response
+func (app *application) apiProblem(w http.ResponseWriter, status int, detail string, fieldErrors map[string]string) {
+	js, err := json.MarshalIndent(problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Errors: fieldErrors,
+	}, "", "\t")
+	if err != nil {
+		app.logger.Error(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// API counterpart of serverError, logs the stack trace
+// and sends a generic problem body, or a 503 for query timeouts
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, models.ErrTimeout) {
+		app.logger.WarnContext(r.Context(), err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+		app.apiProblem(w, http.StatusServiceUnavailable, "the database did not answer in time, try again later", nil)
+		return
+	}
+
+	app.logger.ErrorContext(r.Context(), err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "trace", string(debug.Stack()))
+
+	app.apiProblem(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request", nil)
+}
+
+// API counterpart of readJSON errors
+func (app *application) apiBadRequest(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnsupportedMediaType) {
+		app.apiProblem(w, http.StatusUnsupportedMediaType, err.Error(), nil)
+		return
+	}
+
+	app.apiProblem(w, http.StatusBadRequest, err.Error(), nil)
+}
+
+// 401 response for a missing, malformed or unknown bearer token
+func (app *application) invalidTokenResponse(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.apiProblem(w, http.StatusUnauthorized, "invalid or missing authentication token", nil)
+}
+
+// API counterpart of ownedSnippet, writes a problem response
+// (404 or 403) and returns ok false on failure
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.snippets.GetBySlug(r.Context(), httprouter.ParamsFromContext(r.Context()).ByName("slug"), app.authenticatedUserID(r))
+	if err == nil && snippet.UserID != app.authenticatedUserID(r) {
+		err = errNotOwner
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.apiProblem(w, http.StatusNotFound, "the requested snippet could not be found", nil)
+		case errors.Is(err, errNotOwner):
+			app.apiProblem(w, http.StatusForbidden, "only the owner of a snippet can change it", nil)
+		default:
+			app.apiServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// resolveExpiry for the API, where expires_at (RFC 3339) or never_expires
+// take precedence over a number of days
+func (app *application) apiExpiry(v *validator.Validator, days string, expiresAt *time.Time, neverExpires bool) *time.Time {
+	switch {
+	case neverExpires:
+		return app.resolveExpiry(v, expiresNever, time.Time{})
+	case expiresAt != nil:
+		return app.resolveExpiry(v, expiresCustom, *expiresAt)
+	default:
+		return app.resolveExpiry(v, days, time.Time{})
+	}
+}
+
+// GET /api/v1/snippets
+// latest non-expired public snippets, paged with ?page= and ?size=
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	filters, v := readFilters(r.URL.Query(), app.config.latestPageSize, "created")
+	if !v.Valid() {
+		app.apiProblem(w, http.StatusBadRequest, "invalid query parameters", v.FieldErrors)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.Latest(r.Context(), filters)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"snippets": snippets, "metadata": metadata})
+}
+
+// GET /api/v1/snippets/:slug
+// the slug or the vanity slug, like the snippet page. There is no reveal
+// step in the API, reading a view-limited snippet uses up one of its views
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+	viewerID := app.authenticatedUserID(r)
+
+	snippet, err := app.snippets.GetBySlug(r.Context(), slug, viewerID)
+	if err == nil && snippet.ViewLimited() && snippet.UserID != viewerID {
+		snippet, err = app.snippets.ConsumeView(r.Context(), slug, viewerID)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiProblem(w, http.StatusNotFound, "the requested snippet could not be found", nil)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"snippet": snippet})
+}
+
+// POST /api/v1/snippets
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title        string     `json:"title"`
+		Content      string     `json:"content"`
+		Language     string     `json:"language"`
+		Visibility   string     `json:"visibility"`
+		Expires      int        `json:"expires"`
+		ExpiresAt    *time.Time `json:"expires_at"`
+		NeverExpires bool       `json:"never_expires"`
+		MaxViews     int        `json:"max_views"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	// snippets are public unless asked otherwise
+	if input.Visibility == "" {
+		input.Visibility = models.VisibilityPublic
+	}
+
+	v := &validator.Validator{}
+	validateSnippet(v, input.Title, input.Content)
+	validateLanguage(v, input.Language)
+	validateVisibility(v, input.Visibility)
+	expires := app.apiExpiry(v, strconv.Itoa(input.Expires), input.ExpiresAt, input.NeverExpires)
+	validateMaxViews(v, input.MaxViews)
+
+	if !v.Valid() {
+		app.apiProblem(w, http.StatusUnprocessableEntity, "the snippet failed validation", v.FieldErrors)
+		return
+	}
+
+	id, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), input.Title, input.Content, input.Language, input.Visibility, expires, input.MaxViews)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.metrics.snippetsCreated.Inc()
+
+	snippet, err := app.snippets.Get(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	// the slug, the numeric ID is never handed out
+	w.Header().Set("Location", "/api/v1/snippets/"+snippet.Slug)
+	app.writeJSON(w, r, http.StatusCreated, envelope{"snippet": snippet})
+}
+
+// PUT /api/v1/snippets/:slug
+// replaces title, content, language and visibility, only allowed for the owner.
+// Language, visibility and expiry are kept unless given
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title        string     `json:"title"`
+		Content      string     `json:"content"`
+		Language     *string    `json:"language"`
+		Visibility   string     `json:"visibility"`
+		ExpiresAt    *time.Time `json:"expires_at"`
+		NeverExpires bool       `json:"never_expires"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	// a pointer, as an empty language means auto-detection
+	language := snippet.Language
+	if input.Language != nil {
+		language = *input.Language
+	}
+
+	// visibility is kept unless given
+	if input.Visibility == "" {
+		input.Visibility = snippet.Visibility
+	}
+
+	v := &validator.Validator{}
+	validateSnippet(v, input.Title, input.Content)
+	validateLanguage(v, language)
+	validateVisibility(v, input.Visibility)
+
+	// expiry is kept unless given
+	expires := snippet.Expires
+	if input.ExpiresAt != nil || input.NeverExpires {
+		expires = app.apiExpiry(v, "", input.ExpiresAt, input.NeverExpires)
+	}
+
+	if !v.Valid() {
+		app.apiProblem(w, http.StatusUnprocessableEntity, "the snippet failed validation", v.FieldErrors)
+		return
+	}
+
+	err = app.snippets.Update(r.Context(), snippet.ID, app.authenticatedUserID(r), input.Title, input.Content, language, input.Visibility, snippet.VanitySlug, expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiProblem(w, http.StatusNotFound, "the requested snippet could not be found", nil)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	// copy rather than modify the snippet returned by the model
+	updated := *snippet
+	updated.Title = input.Title
+	updated.Content = input.Content
+	updated.Language = language
+	updated.Visibility = input.Visibility
+	updated.Expires = expires
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"snippet": updated})
+}
+
+// DELETE /api/v1/snippets/:slug
+// only allowed for the owner
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(r.Context(), snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiProblem(w, http.StatusNotFound, "the requested snippet could not be found", nil)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+)
+
+var jsonHeader = http.Header{"Content-Type": {"application/json"}}
+
+func TestAPISnippetList(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/api/v1/snippets")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+	var resp struct {
+		Snippets []map[string]any `json:"snippets"`
+		Metadata map[string]any   `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(resp.Snippets), 1)
+	assert.Equal(t, resp.Snippets[0]["title"], any("An old silent pond"))
+	assert.Equal(t, resp.Snippets[0]["slug"], any("Xk3pQ9rT2a"))
+	// neither the sequential ID nor the account of the owner
+	_, hasID := resp.Snippets[0]["id"]
+	_, hasUserID := resp.Snippets[0]["user_id"]
+	assert.Equal(t, hasID, false)
+	assert.Equal(t, hasUserID, false)
+
+	// snake_case keys, like the snippet fields
+	assert.Equal(t, len(resp.Metadata), 5)
+	assert.Equal(t, resp.Metadata["current_page"], any(float64(1)))
+	assert.Equal(t, resp.Metadata["page_size"], any(float64(10)))
+	assert.Equal(t, resp.Metadata["first_page"], any(float64(1)))
+	assert.Equal(t, resp.Metadata["last_page"], any(float64(1)))
+	assert.Equal(t, resp.Metadata["total_records"], any(float64(1)))
+
+	code, header, _ = ts.get(t, "/api/v1/snippets?page=0")
+
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+}
+
+func TestAPISnippetGet(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantCode   int
+		wantType   string
+		wantInBody string
+	}{
+		{
+			name:       "Valid slug",
+			urlPath:    "/api/v1/snippets/Xk3pQ9rT2a",
+			wantCode:   http.StatusOK,
+			wantType:   "application/json",
+			wantInBody: `"title": "An old silent pond"`,
+		},
+		{
+			name:       "Vanity slug",
+			urlPath:    "/api/v1/snippets/hidden-gem",
+			wantCode:   http.StatusOK,
+			wantType:   "application/json",
+			wantInBody: `"title": "Secret handshake"`,
+		},
+		{
+			// no reveal step, the view is used up
+			name:       "View-limited",
+			urlPath:    "/api/v1/snippets/bUrN4ft3rR",
+			wantCode:   http.StatusOK,
+			wantType:   "application/json",
+			wantInBody: `"content": "hunter2"`,
+		},
+		{
+			name:       "Non-existent slug",
+			urlPath:    "/api/v1/snippets/Zz9zZz9zZz",
+			wantCode:   http.StatusNotFound,
+			wantType:   "application/problem+json",
+			wantInBody: `"status": 404`,
+		},
+		{
+			// sequential IDs would let anyone enumerate snippets
+			name:       "Numeric ID",
+			urlPath:    "/api/v1/snippets/1",
+			wantCode:   http.StatusNotFound,
+			wantType:   "application/problem+json",
+			wantInBody: `"status": 404`,
+		},
+		{
+			name:       "Query timeout",
+			urlPath:    "/api/v1/snippets/sL0wQu3ry0",
+			wantCode:   http.StatusServiceUnavailable,
+			wantType:   "application/problem+json",
+			wantInBody: `"status": 503`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Content-Type"), tt.wantType)
+			assert.Equal(t, strings.Contains(body, tt.wantInBody), true)
+		})
+	}
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, _ := ts.do(t, http.MethodPost, "/api/v1/snippets", jsonHeader, `{"title": "a", "content": "b", "expires": 7}`)
+
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+	})
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		body       string
+		wantCode   int
+		wantInBody string
+	}{
+		{
+			name:       "Valid submission",
+			header:     jsonHeader,
+			body:       `{"title": "O snail", "content": "Climb Mount Fuji", "expires": 7}`,
+			wantCode:   http.StatusCreated,
+			wantInBody: `"snippet"`,
+		},
+		{
+			name:       "Invalid expires",
+			header:     jsonHeader,
+			body:       `{"title": "O snail", "content": "Climb Mount Fuji", "expires": 2}`,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantInBody: `"expires": "This field must equal 1, 7 or 365"`,
+		},
+		{
+			name:       "Never expires",
+			header:     jsonHeader,
+			body:       `{"title": "O snail", "content": "Climb Mount Fuji", "never_expires": true}`,
+			wantCode:   http.StatusCreated,
+			wantInBody: `"snippet"`,
+		},
+		{
+			name:       "Custom expiry",
+			header:     jsonHeader,
+			body:       `{"title": "O snail", "content": "Climb Mount Fuji", "expires_at": "` + time.Now().Add(48*time.Hour).Format(time.RFC3339) + `"}`,
+			wantCode:   http.StatusCreated,
+			wantInBody: `"snippet"`,
+		},
+		{
+			name:       "Custom expiry in the past",
+			header:     jsonHeader,
+			body:       `{"title": "O snail", "content": "Climb Mount Fuji", "expires_at": "2001-01-01T00:00:00Z"}`,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantInBody: `"expires_at": "This field must be in the future"`,
+		},
+		{
+			name:       "Blank title",
+			header:     jsonHeader,
+			body:       `{"title": "", "content": "Climb Mount Fuji", "expires": 7}`,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantInBody: `"title": "This field cannot be blank"`,
+		},
+		{
+			name:       "Badly-formed JSON",
+			header:     jsonHeader,
+			body:       `{"title": "O snail",`,
+			wantCode:   http.StatusBadRequest,
+			wantInBody: "badly-formed JSON",
+		},
+		{
+			name:       "Unknown field",
+			header:     jsonHeader,
+			body:       `{"title": "O snail", "content": "Climb Mount Fuji", "expires": 7, "id": 3}`,
+			wantCode:   http.StatusBadRequest,
+			wantInBody: `unknown key`,
+		},
+		{
+			name:       "Form content type",
+			header:     http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			body:       `title=O+snail`,
+			wantCode:   http.StatusUnsupportedMediaType,
+			wantInBody: `"status": 415`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, "alice@example.com", "pa$$word")
+
+			code, header, body := ts.do(t, http.MethodPost, "/api/v1/snippets", tt.header, tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+			if code == http.StatusCreated {
+				assert.Equal(t, header.Get("Location"), "/api/v1/snippets/Xk3pQ9rT2a")
+			}
+			assert.Equal(t, strings.Contains(body, tt.wantInBody), true)
+		})
+	}
+}
+
+func TestAPISnippetUpdateDelete(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		method   string
+		urlPath  string
+		body     string
+		wantCode int
+	}{
+		{
+			name:     "Update as owner",
+			email:    "alice@example.com",
+			method:   http.MethodPut,
+			urlPath:  "/api/v1/snippets/Xk3pQ9rT2a",
+			body:     `{"title": "New title", "content": "New content"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Update as other user",
+			email:    "bob@example.com",
+			method:   http.MethodPut,
+			urlPath:  "/api/v1/snippets/Xk3pQ9rT2a",
+			body:     `{"title": "New title", "content": "New content"}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Update non-existent",
+			email:    "alice@example.com",
+			method:   http.MethodPut,
+			urlPath:  "/api/v1/snippets/Zz9zZz9zZz",
+			body:     `{"title": "New title", "content": "New content"}`,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Delete as owner",
+			email:    "alice@example.com",
+			method:   http.MethodDelete,
+			urlPath:  "/api/v1/snippets/Xk3pQ9rT2a",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Delete as other user",
+			email:    "bob@example.com",
+			method:   http.MethodDelete,
+			urlPath:  "/api/v1/snippets/Xk3pQ9rT2a",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			code, _, _ := ts.do(t, tt.method, tt.urlPath, jsonHeader, tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestAPISnippetUpdateLanguage(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantLanguage string
+	}{
+		{
+			name:         "Title only",
+			body:         `{"title": "New title", "content": "An old silent pond..."}`,
+			wantLanguage: "plaintext",
+		},
+		{
+			name:         "New language",
+			body:         `{"title": "New title", "content": "An old silent pond...", "language": "go"}`,
+			wantLanguage: "go",
+		},
+		{
+			name:         "Auto-detect",
+			body:         `{"title": "New title", "content": "An old silent pond...", "language": ""}`,
+			wantLanguage: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, "alice@example.com", "pa$$word")
+
+			code, _, body := ts.do(t, http.MethodPut, "/api/v1/snippets/Xk3pQ9rT2a", jsonHeader, tt.body)
+
+			assert.Equal(t, code, http.StatusOK)
+
+			var resp struct {
+				Snippet map[string]any `json:"snippet"`
+			}
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, resp.Snippet["title"], any("New title"))
+			assert.Equal(t, resp.Snippet["language"], any(tt.wantLanguage))
+		})
+	}
+}
+
+func TestAPIBearerToken(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	body := `{"title": "O snail", "content": "Climb Mount Fuji", "expires": 7}`
+
+	tests := []struct {
+		name          string
+		method        string
+		urlPath       string
+		authorization string
+		wantCode      int
+	}{
+		{
+			name:          "Write scope",
+			method:        http.MethodPost,
+			urlPath:       "/api/v1/snippets",
+			authorization: "Bearer sbx_readwrite",
+			wantCode:      http.StatusCreated,
+		},
+		{
+			name:          "Missing write scope",
+			method:        http.MethodPost,
+			urlPath:       "/api/v1/snippets",
+			authorization: "Bearer sbx_readonly",
+			wantCode:      http.StatusForbidden,
+		},
+		{
+			name:          "Read scope",
+			method:        http.MethodGet,
+			urlPath:       "/api/v1/snippets/Xk3pQ9rT2a",
+			authorization: "Bearer sbx_readonly",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "Unknown token",
+			method:        http.MethodGet,
+			urlPath:       "/api/v1/snippets/Xk3pQ9rT2a",
+			authorization: "Bearer sbx_unknown",
+			wantCode:      http.StatusUnauthorized,
+		},
+		{
+			name:          "Token lookup timeout",
+			method:        http.MethodGet,
+			urlPath:       "/api/v1/snippets/Xk3pQ9rT2a",
+			authorization: "Bearer sbx_slow",
+			wantCode:      http.StatusServiceUnavailable,
+		},
+		{
+			name:          "Wrong scheme",
+			method:        http.MethodGet,
+			urlPath:       "/api/v1/snippets/Xk3pQ9rT2a",
+			authorization: "Basic YWxpY2U6cGFzcw==",
+			wantCode:      http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{
+				"Content-Type":  {"application/json"},
+				"Authorization": {tt.authorization},
+			}
+
+			code, rsHeader, _ := ts.do(t, tt.method, tt.urlPath, header, body)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusUnauthorized {
+				assert.Equal(t, rsHeader.Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		method    string
+		urlPath   string
+		wantCode  int
+		wantType  string
+		wantAllow string
+	}{
+		{
+			name:     "Unknown API path",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/nope",
+			wantCode: http.StatusNotFound,
+			wantType: "application/problem+json",
+		},
+		{
+			name:      "API method not allowed",
+			method:    http.MethodPatch,
+			urlPath:   "/api/v1/snippets",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantType:  "application/problem+json",
+			wantAllow: "GET, OPTIONS, POST",
+		},
+		{
+			name:     "Unknown page",
+			method:   http.MethodGet,
+			urlPath:  "/nope",
+			wantCode: http.StatusNotFound,
+			wantType: "text/plain; charset=utf-8",
+		},
+		{
+			name:      "Page method not allowed",
+			method:    http.MethodDelete,
+			urlPath:   "/search",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantType:  "text/plain; charset=utf-8",
+			wantAllow: "GET, OPTIONS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.do(t, tt.method, tt.urlPath, nil, "")
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Content-Type"), tt.wantType)
+			assert.Equal(t, header.Get("Allow"), tt.wantAllow)
+			if tt.wantType == "application/problem+json" {
+				assert.Equal(t, strings.Contains(body, fmt.Sprintf(`"status": %d`, tt.wantCode)), true)
+			}
+		})
+	}
+}
+
+func TestAPIPanic(t *testing.T) {
+	app := newTestApplication(t)
+
+	handler := app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	}))
+
+	for _, tt := range []struct {
+		urlPath  string
+		wantType string
+	}{
+		{urlPath: "/api/v1/snippets", wantType: "application/problem+json"},
+		{urlPath: "/", wantType: "text/plain; charset=utf-8"},
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.urlPath, nil))
+
+		assert.Equal(t, rr.Code, http.StatusInternalServerError)
+		assert.Equal(t, rr.Header().Get("Content-Type"), tt.wantType)
+	}
+}
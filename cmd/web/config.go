@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+	"github.com/Danvs60/snippetbox/internal/settings"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Settings of the web server. Each setting is read, in increasing order of
+// precedence, from its default, an optional JSON config file, a SNIPPETBOX_*
+// environment variable and a command-line flag. The flag name is the
+// setting name, e.g. -session-lifetime is SNIPPETBOX_SESSION_LIFETIME in
+// the environment and "session_lifetime" in the config file
+type config struct {
+	addr             string
+	metricsAddr      string
+	dbDriver         string
+	dsn              string
+	autoMigrate      bool
+	logFormat        string
+	accessLogFormat  string
+	accessLogSample  float64
+	accessLogExclude string
+	traceExporter    string
+	traceEndpoint    string
+	traceFile        string
+	traceSample      float64
+	tlsCert          string
+	tlsKey           string
+	idleTimeout      time.Duration
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
+	shutdownTimeout  time.Duration
+	drainDelay       time.Duration
+	readyTimeout     time.Duration
+	queryTimeout     time.Duration
+	sessionLifetime  time.Duration
+	bcryptCost       int
+	latestPageSize   int
+	maxExpiry        time.Duration
+	purgeInterval    time.Duration
+	purgeGrace       time.Duration
+	purgeBatch       int
+}
+
+// settings used when nothing else is given
+func defaultConfig() config {
+	return config{
+		addr:             ":4000",
+		dbDriver:         models.DriverMySQL,
+		dsn:              "web:st0ngb00ze@/snippetbox?parseTime=true",
+		logFormat:        logFormatText,
+		accessLogFormat:  accessLogCombined,
+		accessLogSample:  1,
+		accessLogExclude: "/static/,/ping",
+		traceExporter:    traceExporterNone,
+		traceSample:      1,
+		tlsCert:          "./tls/cert.pem",
+		tlsKey:           "./tls/key.pem",
+		idleTimeout:      time.Minute,
+		readTimeout:      5 * time.Second,
+		writeTimeout:     10 * time.Second,
+		shutdownTimeout:  20 * time.Second,
+		readyTimeout:     2 * time.Second,
+		queryTimeout:     5 * time.Second,
+		sessionLifetime:  12 * time.Hour,
+		bcryptCost:       12,
+		latestPageSize:   10,
+		maxExpiry:        365 * 24 * time.Hour,
+		purgeInterval:    time.Hour,
+		purgeGrace:       24 * time.Hour,
+		purgeBatch:       500,
+	}
+}
+
+// register a flag for every setting, bound to the fields of cfg
+func (cfg *config) flags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.addr, "addr", cfg.addr, "HTTP Network Address")
+	fs.StringVar(&cfg.metricsAddr, "metrics-addr", cfg.metricsAddr, "Plain HTTP address serving /metrics on its own, instead of with the app")
+	fs.StringVar(&cfg.dbDriver, "db-driver", cfg.dbDriver, "Database backend: "+strings.Join(models.Drivers, ", "))
+	fs.StringVar(&cfg.dsn, "dsn", cfg.dsn, "Data source name, in the format of the -db-driver backend")
+	fs.BoolVar(&cfg.autoMigrate, "auto-migrate", cfg.autoMigrate, "Apply pending schema migrations on startup")
+	fs.StringVar(&cfg.logFormat, "log-format", cfg.logFormat, "Log format: text or json")
+	fs.StringVar(&cfg.accessLogFormat, "access-log-format", cfg.accessLogFormat, "Access log format: "+strings.Join(accessLogFormats, ", "))
+	fs.Float64Var(&cfg.accessLogSample, "access-log-sample", cfg.accessLogSample, "Fraction of the requests written to the access log, server errors always are")
+	fs.StringVar(&cfg.accessLogExclude, "access-log-exclude", cfg.accessLogExclude, "Comma separated paths left out of the access log, those ending with / exclude every path below")
+	fs.StringVar(&cfg.traceExporter, "trace-exporter", cfg.traceExporter, "Where to send OpenTelemetry spans: "+strings.Join(traceExporters, ", "))
+	fs.StringVar(&cfg.traceEndpoint, "trace-endpoint", cfg.traceEndpoint, "OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces, OTEL_EXPORTER_OTLP_* if empty")
+	fs.StringVar(&cfg.traceFile, "trace-file", cfg.traceFile, "File the stdout exporter appends spans to instead of stdout")
+	fs.Float64Var(&cfg.traceSample, "trace-sample", cfg.traceSample, "Fraction of the traces started by the server that are recorded")
+	fs.StringVar(&cfg.tlsCert, "tls-cert", cfg.tlsCert, "TLS certificate file")
+	fs.StringVar(&cfg.tlsKey, "tls-key", cfg.tlsKey, "TLS private key file")
+	fs.DurationVar(&cfg.idleTimeout, "idle-timeout", cfg.idleTimeout, "How long keep-alive connections are kept idle")
+	fs.DurationVar(&cfg.readTimeout, "read-timeout", cfg.readTimeout, "Maximum time to read a request")
+	fs.DurationVar(&cfg.writeTimeout, "write-timeout", cfg.writeTimeout, "Maximum time to write a response")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", cfg.shutdownTimeout, "How long to wait for in-flight requests on shutdown")
+	fs.DurationVar(&cfg.drainDelay, "drain-delay", cfg.drainDelay, "How long /readyz fails before shutting down, for load balancers to stop sending requests")
+	fs.DurationVar(&cfg.readyTimeout, "ready-timeout", cfg.readyTimeout, "Maximum time for the /readyz checks")
+	fs.DurationVar(&cfg.queryTimeout, "query-timeout", cfg.queryTimeout, "Maximum time of the database queries of a snippet, user or token lookup, 0 for none")
+	fs.DurationVar(&cfg.sessionLifetime, "session-lifetime", cfg.sessionLifetime, "How long users stay logged in")
+	fs.IntVar(&cfg.bcryptCost, "bcrypt-cost", cfg.bcryptCost, "bcrypt cost of new password hashes")
+	fs.IntVar(&cfg.latestPageSize, "latest-page-size", cfg.latestPageSize, "Number of latest snippets per page")
+	fs.DurationVar(&cfg.maxExpiry, "max-expiry", cfg.maxExpiry, "Latest expiry allowed for snippets, from now")
+	fs.DurationVar(&cfg.purgeInterval, "purge-interval", cfg.purgeInterval, "Time between purges of expired snippets and sessions")
+	fs.DurationVar(&cfg.purgeGrace, "purge-grace", cfg.purgeGrace, "How long expired snippets and sessions are kept before being purged")
+	fs.IntVar(&cfg.purgeBatch, "purge-batch", cfg.purgeBatch, "Maximum rows deleted per purge statement")
+}
+
+// Load the config from the defaults, the config file, the environment
+// (read with lookupEnv) and the command-line arguments, then validate it.
+// Also returns the arguments left after the flags, the command to run.
+// Usage and flag errors are written to output
+func loadConfig(args []string, lookupEnv func(string) (string, bool), output io.Writer) (config, []string, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+	fs.SetOutput(output)
+	cfg.flags(fs)
+
+	err := settings.Load(fs, args, lookupEnv, false)
+	if err != nil {
+		return config{}, nil, err
+	}
+
+	return cfg, fs.Args(), cfg.validate()
+}
+
+// report every invalid setting at once
+func (cfg config) validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.addr != "", "addr must not be empty")
+	check(cfg.metricsAddr != cfg.addr, "metrics-addr must differ from addr, leave it empty to serve /metrics on addr")
+	check(slices.Contains(models.Drivers, cfg.dbDriver), "db-driver must be one of %s, got %q", strings.Join(models.Drivers, ", "), cfg.dbDriver)
+	check(cfg.dsn != "", "dsn must not be empty")
+	check(cfg.logFormat == logFormatText || cfg.logFormat == logFormatJSON, "log-format must be text or json, got %q", cfg.logFormat)
+	check(slices.Contains(accessLogFormats, cfg.accessLogFormat), "access-log-format must be one of %s, got %q", strings.Join(accessLogFormats, ", "), cfg.accessLogFormat)
+	check(cfg.accessLogSample >= 0 && cfg.accessLogSample <= 1, "access-log-sample must be between 0 and 1, got %g", cfg.accessLogSample)
+	for _, path := range strings.Split(cfg.accessLogExclude, ",") {
+		path = strings.TrimSpace(path)
+		check(path == "" || strings.HasPrefix(path, "/"), "access-log-exclude paths must start with /, got %q", path)
+	}
+	check(slices.Contains(traceExporters, cfg.traceExporter), "trace-exporter must be one of %s, got %q", strings.Join(traceExporters, ", "), cfg.traceExporter)
+	check(cfg.traceSample >= 0 && cfg.traceSample <= 1, "trace-sample must be between 0 and 1, got %g", cfg.traceSample)
+	check(cfg.tlsCert != "", "tls-cert must not be empty")
+	check(cfg.tlsKey != "", "tls-key must not be empty")
+	check(cfg.idleTimeout > 0, "idle-timeout must be positive, got %s", cfg.idleTimeout)
+	check(cfg.readTimeout > 0, "read-timeout must be positive, got %s", cfg.readTimeout)
+	check(cfg.writeTimeout > 0, "write-timeout must be positive, got %s", cfg.writeTimeout)
+	check(cfg.shutdownTimeout > 0, "shutdown-timeout must be positive, got %s", cfg.shutdownTimeout)
+	check(cfg.drainDelay >= 0, "drain-delay must not be negative, got %s", cfg.drainDelay)
+	check(cfg.readyTimeout > 0, "ready-timeout must be positive, got %s", cfg.readyTimeout)
+	check(cfg.queryTimeout >= 0, "query-timeout must not be negative, got %s", cfg.queryTimeout)
+	// otherwise a slow query outlives the response it was for
+	check(cfg.queryTimeout < cfg.writeTimeout, "query-timeout must be shorter than write-timeout (%s), got %s", cfg.writeTimeout, cfg.queryTimeout)
+	check(cfg.sessionLifetime > 0, "session-lifetime must be positive, got %s", cfg.sessionLifetime)
+	check(cfg.bcryptCost >= bcrypt.MinCost && cfg.bcryptCost <= bcrypt.MaxCost, "bcrypt-cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cfg.bcryptCost)
+	// same bounds as the ?size= query parameter
+	check(cfg.latestPageSize > 0 && cfg.latestPageSize <= 100, "latest-page-size must be between 1 and 100, got %d", cfg.latestPageSize)
+	check(cfg.maxExpiry >= 24*time.Hour, "max-expiry must be at least 24h, got %s", cfg.maxExpiry)
+	check(cfg.purgeInterval > 0, "purge-interval must be positive, got %s", cfg.purgeInterval)
+	check(cfg.purgeGrace >= 0, "purge-grace must not be negative, got %s", cfg.purgeGrace)
+	check(cfg.purgeBatch > 0, "purge-batch must be positive, got %d", cfg.purgeBatch)
+
+	return errors.Join(errs...)
+}
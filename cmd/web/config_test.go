@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+)
+
+// lookupEnv backed by a map
+func fakeEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+// write a config file to a temporary directory and return its path
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, _, err := loadConfig(nil, fakeEnv(nil), io.Discard)
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cfg, defaultConfig())
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"addr": ":5000",
+		"session_lifetime": "1h",
+		"bcrypt-cost": 10,
+		"latest_page_size": 20
+	}`)
+
+	env := map[string]string{
+		"SNIPPETBOX_CONFIG":           path,
+		"SNIPPETBOX_SESSION_LIFETIME": "2h",
+		"SNIPPETBOX_BCRYPT_COST":      "11",
+	}
+
+	cfg, _, err := loadConfig([]string{"-bcrypt-cost", "13"}, fakeEnv(env), io.Discard)
+
+	assert.Equal(t, err, nil)
+	// file over default
+	assert.Equal(t, cfg.addr, ":5000")
+	assert.Equal(t, cfg.latestPageSize, 20)
+	// environment over file
+	assert.Equal(t, cfg.sessionLifetime, 2*time.Hour)
+	// flag over environment
+	assert.Equal(t, cfg.bcryptCost, 13)
+	// untouched
+	assert.Equal(t, cfg.readTimeout, 5*time.Second)
+}
+
+func TestLoadConfigFileFlag(t *testing.T) {
+	path := writeConfigFile(t, `{"addr": ":6000"}`)
+	other := writeConfigFile(t, `{"addr": ":7000"}`)
+
+	cfg, _, err := loadConfig([]string{"-config", path}, fakeEnv(map[string]string{"SNIPPETBOX_CONFIG": other}), io.Discard)
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cfg.addr, ":6000")
+}
+
+func TestLoadConfigCommand(t *testing.T) {
+	cfg, args, err := loadConfig([]string{"-db-driver", "sqlite", "migrate", "to", "2"}, fakeEnv(nil), io.Discard)
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cfg.dbDriver, "sqlite")
+	assert.Equal(t, strings.Join(args, " "), "migrate to 2")
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		wantErr []string
+	}{
+		{
+			name:    "Invalid flag value",
+			args:    []string{"-read-timeout", "soon"},
+			wantErr: []string{"read-timeout"},
+		},
+		{
+			name:    "Invalid env value",
+			env:     map[string]string{"SNIPPETBOX_PURGE_BATCH": "lots"},
+			wantErr: []string{`SNIPPETBOX_PURGE_BATCH: invalid value "lots"`},
+		},
+		{
+			name:    "Unknown file setting",
+			file:    `{"adr": ":4000"}`,
+			wantErr: []string{`unknown setting "adr"`},
+		},
+		{
+			name:    "Malformed file",
+			file:    `{"addr": `,
+			wantErr: []string{"config.json"},
+		},
+		{
+			name:    "Unknown database driver",
+			env:     map[string]string{"SNIPPETBOX_DB_DRIVER": "oracle"},
+			wantErr: []string{`db-driver must be one of mysql, sqlite, postgres, got "oracle"`},
+		},
+		{
+			name: "Invalid access log settings",
+			args: []string{"-access-log-format", "clf", "-access-log-sample", "1.5", "-access-log-exclude", "/static/,ping"},
+			wantErr: []string{
+				`access-log-format must be one of common, combined, json, got "clf"`,
+				"access-log-sample must be between 0 and 1, got 1.5",
+				`access-log-exclude paths must start with /, got "ping"`,
+			},
+		},
+		{
+			name: "Invalid trace settings",
+			args: []string{"-trace-exporter", "jaeger", "-trace-sample", "-0.5"},
+			wantErr: []string{
+				`trace-exporter must be one of none, otlp, stdout, got "jaeger"`,
+				"trace-sample must be between 0 and 1, got -0.5",
+			},
+		},
+		{
+			name:    "Query timeout past write timeout",
+			args:    []string{"-query-timeout", "15s", "-write-timeout", "10s"},
+			wantErr: []string{"query-timeout must be shorter than write-timeout (10s), got 15s"},
+		},
+		{
+			name:    "Unknown log format",
+			args:    []string{"-log-format", "xml"},
+			wantErr: []string{`log-format must be text or json, got "xml"`},
+		},
+		{
+			name: "Out of range values",
+			args: []string{"-bcrypt-cost", "40", "-session-lifetime", "0s", "-latest-page-size", "500"},
+			wantErr: []string{
+				"bcrypt-cost must be between 4 and 31, got 40",
+				"session-lifetime must be positive, got 0s",
+				"latest-page-size must be between 1 and 100, got 500",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, tt.file)}, args...)
+			}
+
+			_, _, err := loadConfig(args, fakeEnv(tt.env), io.Discard)
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.wantErr {
+				assert.Equal(t, strings.Contains(err.Error(), want), true)
+			}
+		})
+	}
+}
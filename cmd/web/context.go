@@ -3,3 +3,16 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+// API token used to authenticate the request,
+// not set for session authenticated requests
+const authenticatedTokenContextKey = contextKey("authenticatedToken")
+
+// pattern of the route the request matched, a *string
+// filled in by patternRouter for the metrics
+const routePatternContextKey = contextKey("routePattern")
+
+// ID of the request in the logs, see requestID
+const requestIDContextKey = contextKey("requestID")
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// a single line of a unified diff, Kind is used as CSS class
+type diffLine struct {
+	Kind string
+	Text string
+}
+
+// split text into lines, each ending in a newline as difflib expects.
+// Unlike difflib.SplitLines no empty line is added at the end
+func splitLines(s string) []string {
+	// textarea submissions use CRLF line endings
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return []string{}
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+
+	return lines
+}
+
+// Build a unified diff of the content of two revisions,
+// with 3 lines of context around every change
+func revisionDiff(from, to *models.Revision) ([]diffLine, error) {
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(from.Content),
+		B:        splitLines(to.Content),
+		FromFile: fmt.Sprintf("revision %d", from.Number),
+		ToFile:   fmt.Sprintf("revision %d", to.Number),
+		Context:  3,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []diffLine{}
+	for i, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		kind := "context"
+		switch {
+		// only the file names come first, later lines starting with
+		// --- or +++ are content, e.g. a removed "-- comment"
+		case i < 2:
+			kind = "header"
+		case strings.HasPrefix(line, "@@"):
+			kind = "hunk"
+		case strings.HasPrefix(line, "+"):
+			kind = "added"
+		case strings.HasPrefix(line, "-"):
+			kind = "removed"
+		}
+
+		lines = append(lines, diffLine{Kind: kind, Text: line})
+	}
+
+	return lines, nil
+}
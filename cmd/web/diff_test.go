@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+func TestRevisionDiff(t *testing.T) {
+	from := &models.Revision{Number: 1, Content: "one\ntwo\nthree\n"}
+	to := &models.Revision{Number: 2, Content: "one\n2\nthree\n"}
+
+	diff, err := revisionDiff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []diffLine{
+		{Kind: "header", Text: "--- revision 1"},
+		{Kind: "header", Text: "+++ revision 2"},
+		{Kind: "hunk", Text: "@@ -1,3 +1,3 @@"},
+		{Kind: "context", Text: " one"},
+		{Kind: "removed", Text: "-two"},
+		{Kind: "added", Text: "+2"},
+		{Kind: "context", Text: " three"},
+	}
+
+	assert.Equal(t, len(diff), len(want))
+	for i := range want {
+		if i < len(diff) {
+			assert.Equal(t, diff[i], want[i])
+		}
+	}
+
+	// identical content gives an empty diff
+	diff, err = revisionDiff(from, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(diff), 0)
+}
+
+func TestRevisionDiffHeaderLikeContent(t *testing.T) {
+	from := &models.Revision{Number: 1, Content: "SELECT 1;\n-- comment\n"}
+	to := &models.Revision{Number: 2, Content: "SELECT 1;\n++i;\n"}
+
+	diff, err := revisionDiff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []diffLine{
+		{Kind: "header", Text: "--- revision 1"},
+		{Kind: "header", Text: "+++ revision 2"},
+		{Kind: "hunk", Text: "@@ -1,2 +1,2 @@"},
+		{Kind: "context", Text: " SELECT 1;"},
+		{Kind: "removed", Text: "--- comment"},
+		{Kind: "added", Text: "+++i;"},
+	}
+
+	assert.Equal(t, len(diff), len(want))
+	for i := range want {
+		if i < len(diff) {
+			assert.Equal(t, diff[i], want[i])
+		}
+	}
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
-	"strconv"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/Danvs60/snippetbox/internal/models"
 	"github.com/Danvs60/snippetbox/internal/validator"
@@ -12,49 +15,278 @@ import (
 )
 
 // Define home handler function
+// Lists the latest snippets, paged with ?page= and ?size=
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	filters, v := readFilters(r.URL.Query(), app.config.latestPageSize, "created")
+	if !v.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.Latest(r.Context(), filters)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.Filters = filters
+	data.Metadata = metadata
 
-	app.render(w, http.StatusOK, "home.tmpl", data)
+	app.render(w, r, http.StatusOK, "home.tmpl", data)
 }
 
 // Define snippetView handler function
 // Writes a snippet's content
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	// get params (httprouter)
-	params := httprouter.ParamsFromContext(r.Context())
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// view-limited snippets are only shown once the reader confirms with a
+	// POST, link previews and crawlers only GET the page so they can't burn
+	// a view. The owner can always see their own snippet
+	if snippet.ViewLimited() && snippet.UserID != app.authenticatedUserID(r) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+
+		app.render(w, r, http.StatusOK, "reveal.tmpl", data)
+		return
+	}
+
+	app.renderSnippet(w, r, snippet, false)
+}
+
+// Show a view-limited snippet, using up one of its views
+func (app *application) snippetRevealPost(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	snippet, err := app.snippets.ConsumeView(r.Context(), slug, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// the snippet may be gone by now, don't let anything keep a copy
+	w.Header().Set("Cache-Control", "no-store")
+
+	app.renderSnippet(w, r, snippet, true)
+}
+
+// render view.tmpl, revealed is true when the view used up
+// one of the snippet's remaining views
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, snippet *models.Snippet, revealed bool) {
+	highlighted, err := highlightCode(snippet.Content, snippet.Language)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Highlighted = highlighted
+	data.Revealed = revealed
 
-	// Extract value of id parameter
-	id_param := params.ByName("id")
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+// fetch the snippet from the :slug route parameter, writing a 404
+// or 500 response and returning ok false on failure
+func (app *application) viewableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	snippet, err := app.snippets.GetBySlug(r.Context(), slug, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
 
-	// Validate it is an integer
-	id, err := strconv.Atoi(id_param)
-	if err != nil || id < 1 {
+	return snippet, true
+}
+
+// like viewableSnippet, but view-limited snippets are only readable by
+// their owner, everybody else has to go through the reveal page
+func (app *application) readableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.ViewLimited() && snippet.UserID != app.authenticatedUserID(r) {
 		app.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Redirect the old numeric snippet URLs to /s/:slug followed by suffix,
+// so existing links keep working. Get only finds public snippets and the
+// viewer's own, so unlisted snippets can't be found by enumerating IDs
+func (app *application) redirectToSlug(suffix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := idParam(r)
+		if !ok {
+			app.notFound(w)
+			return
+		}
+
+		snippet, err := app.snippets.Get(r.Context(), id, app.authenticatedUserID(r))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		// keep the query string, e.g. ?from= and ?to= of the diff page
+		target := url.URL{Path: snippetPath(snippet, suffix), RawQuery: r.URL.RawQuery}
+
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	}
+}
+
+// Serve the bare snippet content as plain text, e.g. for curl
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	writeRawSnippet(w, snippet)
+}
+
+// Serve the snippet content as a file attachment named after its title
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": snippetFilename(snippet),
+	}))
+
+	writeRawSnippet(w, snippet)
+}
+
+// List the revisions of a snippet
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	revisions, err := app.snippets.Revisions(r.Context(), snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	app.render(w, r, http.StatusOK, "history.tmpl", data)
+}
+
+// Show a unified diff between two revisions, ?from= and ?to= revision numbers
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	v := &validator.Validator{}
+	fromNumber := readInt(qs, "from", 0, v)
+	toNumber := readInt(qs, "to", 0, v)
+	if !v.Valid() || fromNumber < 1 || toNumber < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	from, err := app.snippets.GetRevision(r.Context(), snippet.ID, fromNumber)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	to, err := app.snippets.GetRevision(r.Context(), snippet.ID, toNumber)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	diff, err := revisionDiff(from, to)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.FromRevision = from
+	data.ToRevision = to
+	data.Diff = diff
+
+	app.render(w, r, http.StatusOK, "diff.tmpl", data)
+}
 
-	app.render(w, http.StatusOK, "view.tmpl", data)
+type snippetRollbackForm struct {
+	Revision int `form:"revision"`
+}
+
+// Restore an old revision, only allowed for the owner
+func (app *application) snippetRollbackPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetRollbackForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.Revision < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.snippets.Rollback(r.Context(), snippet.ID, form.Revision, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet rolled back to revision %d!", form.Revision))
+
+	http.Redirect(w, r, snippetPath(snippet, ""), http.StatusSeeOther)
 }
 
 // form struct to represent data and validation
@@ -62,7 +294,11 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 type snippetCreateForm struct {
 	Title               string     `form:"title"`
 	Content             string     `form:"content"`
-	Expires             int        `form:"expires"`
+	Language            string     `form:"language"`
+	Visibility          string     `form:"visibility"`
+	Expires             string     `form:"expires"`
+	ExpiresAt           string     `form:"expires_at"`
+	MaxViews            int        `form:"max_views"`
 	validator.Validator `form:"-"` // - tells decoder to ignore a field during decoding
 }
 
@@ -70,10 +306,13 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Language:   autoDetectLanguage,
+		Visibility: models.VisibilityPublic,
+		Expires:    "365",
 	}
+	data.Languages = languages
 
-	app.render(w, http.StatusOK, "create.tmpl", data)
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 // Define a snippetCreate handler function
@@ -87,29 +326,319 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	validateSnippet(&form.Validator, form.Title, form.Content)
+	validateLanguage(&form.Validator, form.Language)
+	validateVisibility(&form.Validator, form.Visibility)
+	expires := app.readExpiryForm(&form.Validator, form.Expires, form.ExpiresAt)
+	validateMaxViews(&form.Validator, form.MaxViews)
 
 	// re-display create.tmpl if there are any errors to display
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+		data.Languages = languages
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
 		return
 	}
 
 	// Pass snippet data to connection pool for insert
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// the snippet is owned by the user creating it
+	id, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), form.Title, form.Content, form.Language, form.Visibility, expires, form.MaxViews)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.metrics.snippetsCreated.Inc()
+
+	// fetch it back for its generated slug
+	snippet, err := app.snippets.Get(r.Context(), id, app.authenticatedUserID(r))
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, snippetPath(snippet, ""), http.StatusSeeOther)
+}
+
+// edit form allows changing everything but the view limit, which is
+// fixed when the snippet is created
+type snippetEditForm struct {
+	ID                  int        `form:"-"`
+	Slug                string     `form:"-"`
+	CurrentExpires      *time.Time `form:"-"`
+	Title               string     `form:"title"`
+	Content             string     `form:"content"`
+	Language            string     `form:"language"`
+	Visibility          string     `form:"visibility"`
+	VanitySlug          string     `form:"vanity_slug"`
+	Expires             string     `form:"expires"`
+	ExpiresAt           string     `form:"expires_at"`
+	validator.Validator `form:"-"`
+}
+
+// Render the edit form pre-filled with the snippet being edited
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	form := snippetEditForm{
+		ID:             snippet.ID,
+		Slug:           snippet.Slug,
+		CurrentExpires: snippet.Expires,
+		Title:          snippet.Title,
+		Content:        snippet.Content,
+		Language:       snippet.Language,
+		Visibility:     snippet.Visibility,
+		VanitySlug:     snippet.VanitySlug,
+		Expires:        expiresKeep,
+	}
+	// pre-fill the custom expiry with the current one
+	if snippet.Expires != nil {
+		form.ExpiresAt = snippet.Expires.UTC().Format(expiresAtLayout)
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Languages = languages
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+// Update an existing snippet, only allowed for its owner
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.ID = snippet.ID
+	form.Slug = snippet.Slug
+	form.CurrentExpires = snippet.Expires
+
+	validateSnippet(&form.Validator, form.Title, form.Content)
+	validateLanguage(&form.Validator, form.Language)
+	validateVisibility(&form.Validator, form.Visibility)
+	validateVanitySlug(&form.Validator, form.VanitySlug)
+
+	// the owner can extend or shorten the expiry,
+	// which is left alone when not given
+	if form.Expires == "" {
+		form.Expires = expiresKeep
+	}
+
+	expires := snippet.Expires
+	if form.Expires != expiresKeep {
+		expires = app.readExpiryForm(&form.Validator, form.Expires, form.ExpiresAt)
+	}
+
+	if form.Valid() {
+		err = app.snippets.Update(r.Context(), snippet.ID, app.authenticatedUserID(r), form.Title, form.Content, form.Language, form.Visibility, form.VanitySlug, expires)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrDuplicateSlug):
+				form.AddFieldError("vanity_slug", "This slug is already taken")
+			case errors.Is(err, models.ErrNoRecord):
+				app.notFound(w)
+				return
+			default:
+				app.serverError(w, r, err)
+				return
+			}
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.Languages = languages
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	updated := *snippet
+	updated.VanitySlug = form.VanitySlug
+
+	http.Redirect(w, r, snippetPath(&updated, ""), http.StatusSeeOther)
+}
+
+// Delete a snippet, only allowed for its owner
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(r.Context(), snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// List the authenticated user's snippets, including expired ones.
+// Supports ?page=, ?size= and ?sort=created|expires
+func (app *application) accountSnippets(w http.ResponseWriter, r *http.Request) {
+	filters, v := readFilters(r.URL.Query(), 20, "created", "expires")
+	if !v.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.ListByUser(r.Context(), app.authenticatedUserID(r), filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Filters = filters
+	data.Metadata = metadata
+
+	app.render(w, r, http.StatusOK, "dashboard.tmpl", data)
+}
+
+// Full-text search over snippet titles and content with ?q=,
+// paged with ?page= and ?size=
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	filters, v := readFilters(qs, 10, "relevance")
+	if !v.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Query = strings.TrimSpace(qs.Get("q"))
+	data.Filters = filters
+
+	// an empty search just renders the search form
+	if data.Query == "" {
+		app.render(w, r, http.StatusOK, "search.tmpl", data)
+		return
+	}
+
+	if !validator.MaxChars(data.Query, 100) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.Search(r.Context(), data.Query, filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Snippets = snippets
+	data.Metadata = metadata
+
+	app.render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
+type tokenCreateForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	validator.Validator `form:"-"`
+}
+
+// render the tokens page for the authenticated user
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, status int, form tokenCreateForm, newToken string) {
+	tokens, err := app.tokens.ListByUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Tokens = tokens
+	data.NewToken = newToken
+	data.Scopes = models.AllScopes
+
+	app.render(w, r, status, "tokens.tmpl", data)
+}
+
+// List the authenticated user's API tokens
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, http.StatusOK, tokenCreateForm{Scopes: []string{models.ScopeSnippetsRead}}, "")
+}
+
+// Create a new API token
+func (app *application) accountTokensPost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Select at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.AllScopes...), "scopes", "Unknown scope")
+	}
+
+	if !form.Valid() {
+		app.renderTokens(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	plaintext, err := app.tokens.Insert(r.Context(), app.authenticatedUserID(r), form.Name, form.Scopes)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// render instead of redirecting: the plaintext token is only shown
+	// this once and must not end up stored in the session as a flash
+	app.renderTokens(w, r, http.StatusOK, tokenCreateForm{Scopes: []string{models.ScopeSnippetsRead}}, plaintext)
+}
+
+// Revoke one of the authenticated user's API tokens
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	err := app.tokens.Revoke(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token revoked!")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
 }
 
 type userSignupForm struct {
@@ -123,7 +652,7 @@ type userSignupForm struct {
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.tmpl", data)
+	app.render(w, r, http.StatusOK, "signup.tmpl", data)
 }
 
 // Post signup form
@@ -147,21 +676,21 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		return
 	}
 
 	// if form is valid, we create a new user
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	err = app.users.Insert(r.Context(), form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 
 		return
@@ -182,7 +711,7 @@ type userLoginForm struct {
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login.tmpl", data)
+	app.render(w, r, http.StatusOK, "login.tmpl", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -202,21 +731,22 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 		return
 	}
 
 	// authenticate
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	id, err := app.users.Authenticate(r.Context(), form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.metrics.logins.WithLabelValues("failure").Inc()
 			form.AddNonFieldError("Email or password is incorrect")
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
@@ -224,11 +754,12 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	// Renew token to refresh current session ID. Good practice to generate new session id when user authenticates or changes privileges
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.metrics.logins.WithLabelValues("success").Inc()
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
 }
 
@@ -236,7 +767,7 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	// renew session token when logging user out
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -2,9 +2,13 @@ package main
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestPing(t *testing.T) {
@@ -21,3 +25,1056 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, code, http.StatusOK)
 	assert.Equal(t, body, "OK")
 }
+
+func TestSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid slug",
+			urlPath:  "/s/Xk3pQ9rT2a",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Vanity slug",
+			urlPath:  "/s/hidden-gem",
+			wantCode: http.StatusOK,
+			wantBody: "Only people with the link",
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/s/Zz9zZz9zZz",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Slug is case sensitive",
+			urlPath:  "/s/xk3pq9rt2a",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Query timeout",
+			urlPath:  "/s/sL0wQu3ry0",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "Service Unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.Contains(body, tt.wantBody), true)
+			}
+		})
+	}
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, _ := ts.get(t, "/snippet/edit/1")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "alice@example.com", "pa$$word")
+
+		code, _, body := ts.get(t, "/snippet/edit/1")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, strings.Contains(body, "<form action='/snippet/edit/1' method='POST'>"), true)
+	})
+
+	t.Run("Not owner", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "bob@example.com", "pa$$word")
+
+		code, _, _ := ts.get(t, "/snippet/edit/1")
+
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+}
+
+func TestSnippetEditPost(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		title        string
+		content      string
+		vanitySlug   string
+		expires      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid submission",
+			email:        "alice@example.com",
+			urlPath:      "/snippet/edit/1",
+			title:        "A new title",
+			content:      "Some new content",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/s/Xk3pQ9rT2a",
+		},
+		{
+			name:         "Vanity slug",
+			email:        "alice@example.com",
+			urlPath:      "/snippet/edit/1",
+			title:        "A new title",
+			content:      "Some new content",
+			vanitySlug:   "old-pond",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/s/old-pond",
+		},
+		{
+			name:         "Never expires",
+			email:        "alice@example.com",
+			urlPath:      "/snippet/edit/1",
+			title:        "A new title",
+			content:      "Some new content",
+			expires:      "never",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/s/Xk3pQ9rT2a",
+		},
+		{
+			name:     "Invalid expires",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/edit/1",
+			title:    "A new title",
+			content:  "Some new content",
+			expires:  "2",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Vanity slug taken",
+			email:      "alice@example.com",
+			urlPath:    "/snippet/edit/1",
+			title:      "A new title",
+			content:    "Some new content",
+			vanitySlug: "taken",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Invalid vanity slug",
+			email:      "alice@example.com",
+			urlPath:    "/snippet/edit/1",
+			title:      "A new title",
+			content:    "Some new content",
+			vanitySlug: "Old Pond",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Blank title",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/edit/1",
+			title:    "",
+			content:  "Some new content",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Not owner",
+			email:    "bob@example.com",
+			urlPath:  "/snippet/edit/1",
+			title:    "A new title",
+			content:  "Some new content",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/edit/2",
+			title:    "A new title",
+			content:  "Some new content",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			csrfToken := ts.login(t, tt.email, "pa$$word")
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("visibility", "public")
+			form.Add("vanity_slug", tt.vanitySlug)
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantLocation != "" {
+				assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestSnippetDeletePost(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Owner",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/delete/1",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Not owner",
+			email:    "bob@example.com",
+			urlPath:  "/snippet/delete/1",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/delete/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			csrfToken := ts.login(t, tt.email, "pa$$word")
+
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestAccountSnippets(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, _ := ts.get(t, "/account/snippets")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Own snippets",
+			email:    "alice@example.com",
+			urlPath:  "/account/snippets",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Sorted by expiry",
+			email:    "alice@example.com",
+			urlPath:  "/account/snippets?sort=expires&page=1&size=5",
+			wantCode: http.StatusOK,
+			wantBody: "Page 1 of 1",
+		},
+		{
+			name:     "No snippets",
+			email:    "bob@example.com",
+			urlPath:  "/account/snippets",
+			wantCode: http.StatusOK,
+			wantBody: "You haven't created any snippets yet.",
+		},
+		{
+			name:     "Invalid page",
+			email:    "alice@example.com",
+			urlPath:  "/account/snippets?page=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid size",
+			email:    "alice@example.com",
+			urlPath:  "/account/snippets?size=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid sort",
+			email:    "alice@example.com",
+			urlPath:  "/account/snippets?sort=title",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email, "pa$$word")
+
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.Contains(body, tt.wantBody), true)
+			}
+		})
+	}
+}
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "First page",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: "Page 1 of 1 (1 snippets)",
+		},
+		{
+			name:     "Explicit page",
+			urlPath:  "/?page=1&size=5",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/?page=-1",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Page size too large",
+			urlPath:  "/?size=1000",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.Contains(body, tt.wantBody), true)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Empty query",
+			urlPath:  "/search",
+			wantCode: http.StatusOK,
+			wantBody: "<form action='/search' method='GET' class='search'>",
+		},
+		{
+			name:     "Match",
+			urlPath:  "/search?q=silent",
+			wantCode: http.StatusOK,
+			wantBody: "An old <mark>silent</mark> pond",
+		},
+		{
+			name:     "No match",
+			urlPath:  "/search?q=frog",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets match your search.",
+		},
+		{
+			name:     "Query too long",
+			urlPath:  "/search?q=" + strings.Repeat("a", 101),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/search?q=silent&page=foo",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.Contains(body, tt.wantBody), true)
+			}
+		})
+	}
+}
+
+func TestAccountTokens(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t, "alice@example.com", "pa$$word")
+
+	t.Run("List", func(t *testing.T) {
+		code, _, body := ts.get(t, "/account/tokens")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, strings.Contains(body, "CI job"), true)
+		assert.Equal(t, strings.Contains(body, "snippets:read, snippets:write"), true)
+	})
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		form     url.Values
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Create",
+			urlPath:  "/account/tokens",
+			form:     url.Values{"name": {"Laptop"}, "scopes": {"snippets:read", "snippets:write"}},
+			wantCode: http.StatusOK,
+			wantBody: "sbx_newtoken",
+		},
+		{
+			name:     "Blank name",
+			urlPath:  "/account/tokens",
+			form:     url.Values{"name": {""}, "scopes": {"snippets:read"}},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Unknown scope",
+			urlPath:  "/account/tokens",
+			form:     url.Values{"name": {"Laptop"}, "scopes": {"admin"}},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Unknown scope",
+		},
+		{
+			name:     "No scopes",
+			urlPath:  "/account/tokens",
+			form:     url.Values{"name": {"Laptop"}},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Select at least one scope",
+		},
+		{
+			name:     "Revoke",
+			urlPath:  "/account/tokens/revoke/1",
+			form:     url.Values{},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Revoke unknown",
+			urlPath:  "/account/tokens/revoke/3",
+			form:     url.Values{},
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, tt.form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.Contains(body, tt.wantBody), true)
+			}
+		})
+	}
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/s/Xk3pQ9rT2a/raw")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, header.Get("Content-Security-Policy"), "default-src 'none'; sandbox")
+	assert.Equal(t, header.Get("Content-Disposition"), "")
+	assert.Equal(t, body, "An old silent pond...")
+
+	code, _, _ = ts.get(t, "/s/Zz9zZz9zZz/raw")
+
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetDownload(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/s/Xk3pQ9rT2a/download")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, header.Get("Content-Disposition"), "attachment; filename=An-old-silent-pond.txt")
+	assert.Equal(t, body, "An old silent pond...")
+
+	code, _, _ = ts.get(t, "/s/Zz9zZz9zZz/download")
+
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t, "alice@example.com", "pa$$word")
+
+	tests := []struct {
+		name       string
+		language   string
+		visibility string
+		expires    string
+		expiresAt  string
+		maxViews   string
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:       "Valid language",
+			language:   "go",
+			visibility: "public",
+			expires:    "7",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Auto-detect",
+			language:   "",
+			visibility: "unlisted",
+			expires:    "7",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Unknown language",
+			language:   "klingon",
+			visibility: "private",
+			expires:    "7",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must be a supported language",
+		},
+		{
+			name:       "Invalid expires",
+			language:   "go",
+			visibility: "public",
+			expires:    "2",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must equal 1, 7 or 365",
+		},
+		{
+			name:       "Never expires",
+			language:   "go",
+			visibility: "public",
+			expires:    "never",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Custom expiry",
+			language:   "go",
+			visibility: "public",
+			expires:    "custom",
+			expiresAt:  time.Now().UTC().Add(48 * time.Hour).Format("2006-01-02T15:04"),
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Custom expiry in the past",
+			language:   "go",
+			visibility: "public",
+			expires:    "custom",
+			expiresAt:  "2001-01-01T00:00",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must be in the future",
+		},
+		{
+			name:       "Custom expiry too far away",
+			language:   "go",
+			visibility: "public",
+			expires:    "custom",
+			expiresAt:  time.Now().UTC().AddDate(2, 0, 0).Format("2006-01-02T15:04"),
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must be at most 365 days from now",
+		},
+		{
+			name:       "Malformed custom expiry",
+			language:   "go",
+			visibility: "public",
+			expires:    "custom",
+			expiresAt:  "tomorrow",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must be a valid date and time",
+		},
+		{
+			name:       "Invalid visibility",
+			language:   "go",
+			visibility: "secret",
+			expires:    "7",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must equal public, unlisted or private",
+		},
+		{
+			name:       "Burn after reading",
+			language:   "plaintext",
+			visibility: "unlisted",
+			expires:    "1",
+			maxViews:   "1",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Invalid max views",
+			language:   "plaintext",
+			visibility: "unlisted",
+			expires:    "1",
+			maxViews:   "101",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must be between 0 and 100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("content", "Climb Mount Fuji")
+			form.Add("language", tt.language)
+			form.Add("visibility", tt.visibility)
+			form.Add("expires", tt.expires)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("max_views", tt.maxViews)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.Contains(body, tt.wantBody), true)
+			}
+		})
+	}
+
+	// only the snippets that passed validation are counted
+	created := 0
+	for _, tt := range tests {
+		if tt.wantCode == http.StatusSeeOther {
+			created++
+		}
+	}
+	assert.Equal(t, testutil.ToFloat64(app.metrics.snippetsCreated), float64(created))
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/s/Xk3pQ9rT2a/history")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "An old pond"), true)
+	assert.Equal(t, strings.Contains(body, "/s/Xk3pQ9rT2a/diff?from=1&to=2"), true)
+	// anonymous visitors can't roll back
+	assert.Equal(t, strings.Contains(body, "/snippet/rollback/1"), false)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body = ts.get(t, "/s/Xk3pQ9rT2a/history")
+
+	assert.Equal(t, strings.Contains(body, "/snippet/rollback/1"), true)
+
+	code, _, _ = ts.get(t, "/s/Zz9zZz9zZz/history")
+
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetDiff(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid revisions",
+			urlPath:  "/s/Xk3pQ9rT2a/diff?from=1&to=2",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='added'>&#43;An old silent pond...</span>",
+		},
+		{
+			name:     "Title change",
+			urlPath:  "/s/Xk3pQ9rT2a/diff?from=1&to=2",
+			wantCode: http.StatusOK,
+			wantBody: "Title changed from <del>An old pond</del>",
+		},
+		{
+			name:     "Missing revision",
+			urlPath:  "/s/Xk3pQ9rT2a/diff?from=1&to=3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Missing parameters",
+			urlPath:  "/s/Xk3pQ9rT2a/diff",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/s/Zz9zZz9zZz/diff?from=1&to=2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, strings.Contains(body, tt.wantBody), true)
+			}
+		})
+	}
+}
+
+func TestSnippetRollbackPost(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		revision string
+		wantCode int
+	}{
+		{
+			name:     "Owner",
+			email:    "alice@example.com",
+			revision: "1",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Not owner",
+			email:    "bob@example.com",
+			revision: "1",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Missing revision",
+			email:    "alice@example.com",
+			revision: "5",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid revision",
+			email:    "alice@example.com",
+			revision: "foo",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			csrfToken := ts.login(t, tt.email, "pa$$word")
+
+			form := url.Values{}
+			form.Add("revision", tt.revision)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, "/snippet/rollback/1", form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetVisibility(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Private as anonymous",
+			urlPath:  "/s/Pr1vAt3zQx",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private as other user",
+			email:    "bob@example.com",
+			urlPath:  "/s/Pr1vAt3zQx",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private as owner",
+			email:    "alice@example.com",
+			urlPath:  "/s/Pr1vAt3zQx",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Raw private as other user",
+			email:    "bob@example.com",
+			urlPath:  "/s/Pr1vAt3zQx/raw",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unlisted by slug as anonymous",
+			urlPath:  "/s/uNl1st3dAb",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Unlisted by ID as anonymous",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unlisted by ID as owner",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusMovedPermanently,
+		},
+		{
+			name:     "API private as anonymous",
+			urlPath:  "/api/v1/snippets/Pr1vAt3zQx",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "API private as owner",
+			email:    "alice@example.com",
+			urlPath:  "/api/v1/snippets/Pr1vAt3zQx",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email, "pa$$word")
+			}
+
+			code, _, _ := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetLegacyRedirect(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "View",
+			urlPath:      "/snippet/view/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/s/Xk3pQ9rT2a",
+		},
+		{
+			name:         "History",
+			urlPath:      "/snippet/view/1/history",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/s/Xk3pQ9rT2a/history",
+		},
+		{
+			name:         "Diff keeps query",
+			urlPath:      "/snippet/view/1/diff?from=1&to=2",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/s/Xk3pQ9rT2a/diff?from=1&to=2",
+		},
+		{
+			name:         "Raw",
+			urlPath:      "/snippet/raw/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/s/Xk3pQ9rT2a/raw",
+		},
+		{
+			name:         "Download",
+			urlPath:      "/snippet/download/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/s/Xk3pQ9rT2a/download",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/view/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/snippet/view/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetBurnAfterReading(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("Interstitial", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, body := ts.get(t, "/s/bUrN4ft3rR")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("Cache-Control"), "no-store")
+		assert.Equal(t, strings.Contains(body, "This snippet burns after reading"), true)
+		assert.Equal(t, strings.Contains(body, "hunter2"), false)
+	})
+
+	t.Run("Reveal", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		_, _, body := ts.get(t, "/s/bUrN4ft3rR")
+
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, header, body := ts.postForm(t, "/s/bUrN4ft3rR", form)
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("Cache-Control"), "no-store")
+		assert.Equal(t, strings.Contains(body, "hunter2"), true)
+		assert.Equal(t, strings.Contains(body, "This was the last view"), true)
+	})
+
+	t.Run("Reveal without CSRF token", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, _ := ts.postForm(t, "/s/bUrN4ft3rR", url.Values{})
+
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "alice@example.com", "pa$$word")
+
+		code, _, body := ts.get(t, "/s/bUrN4ft3rR")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, strings.Contains(body, "hunter2"), true)
+		assert.Equal(t, strings.Contains(body, "1 views left"), true)
+	})
+
+	t.Run("Not readable elsewhere", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		for _, urlPath := range []string{
+			"/s/bUrN4ft3rR/raw",
+			"/s/bUrN4ft3rR/download",
+			"/s/bUrN4ft3rR/history",
+			"/snippet/view/5",
+			"/api/v1/snippets/5",
+		} {
+			code, _, _ := ts.get(t, urlPath)
+
+			assert.Equal(t, code, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// a dependency the server needs to handle requests, checked by /readyz
+type readyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// outcome of a readyCheck in the /readyz response
+type checkResult struct {
+	Status  string `json:"status"` // "ok" or "fail"
+	Latency string `json:"latency"`
+}
+
+// Liveness: the process is up and serving requests,
+// whatever the state of its dependencies
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, envelope{"status": "ok"})
+}
+
+// Readiness: every dependency is reachable within the ready timeout and the
+// server isn't shutting down. Responds 503 otherwise, so load balancers stop
+// sending requests. The checks run concurrently and are reported one by one
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.readyTimeout)
+	defer cancel()
+
+	results := make(map[string]checkResult, len(app.readyChecks))
+	failed := false
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range app.readyChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := c.check(ctx)
+			result := checkResult{Status: "ok", Latency: time.Since(start).String()}
+			if err != nil {
+				// the error can name hosts and addresses,
+				// so it's only logged and not sent to the client
+				app.logger.ErrorContext(ctx, "readiness check failed", "check", c.name, "error", err)
+				result.Status = "fail"
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[c.name] = result
+			failed = failed || err != nil
+		}()
+	}
+
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	switch {
+	case app.draining.Load():
+		status, code = "draining", http.StatusServiceUnavailable
+	case failed:
+		status, code = "fail", http.StatusServiceUnavailable
+	}
+
+	app.writeJSON(w, r, code, envelope{"status": status, "checks": results})
+}
+
+// the pages failed to parse if the cache is empty
+func (app *application) checkTemplates(ctx context.Context) error {
+	if len(app.templateCache) == 0 {
+		return errors.New("template cache is empty")
+	}
+
+	return nil
+}
+
+// Check that store can look a session up. None of the database stores
+// take a context, so the lookup is abandoned rather than cancelled
+// when ctx is done
+func checkSessionStore(store scs.Store) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		// no session has this token, scs tokens are 43 characters long
+		const token = "readyz"
+
+		if cs, ok := store.(scs.CtxStore); ok {
+			_, _, err := cs.FindCtx(ctx, token)
+			return err
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			_, _, err := store.Find(token)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
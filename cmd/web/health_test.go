@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	// liveness doesn't depend on anything
+	app.readyChecks = []readyCheck{
+		{name: "database", check: func(context.Context) error { return errors.New("down") }},
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/healthz")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "{\n\t\"status\": \"ok\"\n}\n")
+}
+
+func TestReadyz(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+	// blocks past the ready timeout
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name       string
+		checks     []readyCheck
+		draining   bool
+		wantCode   int
+		wantStatus string
+		wantChecks map[string]string // check name to status
+		wantLogged string            // error logged but not sent
+	}{
+		{
+			name:       "Ready",
+			checks:     []readyCheck{{name: "database", check: ok}, {name: "sessions", check: ok}},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+			wantChecks: map[string]string{"database": "ok", "sessions": "ok"},
+		},
+		{
+			name:       "Failing check",
+			checks:     []readyCheck{{name: "database", check: down}, {name: "sessions", check: ok}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "fail",
+			wantChecks: map[string]string{"database": "fail", "sessions": "ok"},
+			wantLogged: "connection refused",
+		},
+		{
+			name:       "Timed out check",
+			checks:     []readyCheck{{name: "database", check: hang}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "fail",
+			wantChecks: map[string]string{"database": "fail"},
+			wantLogged: "context deadline exceeded",
+		},
+		{
+			name:       "Draining",
+			checks:     []readyCheck{{name: "database", check: ok}},
+			draining:   true,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "draining",
+			wantChecks: map[string]string{"database": "ok"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			app := newTestApplication(t)
+			app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+			app.config.readyTimeout = 50 * time.Millisecond
+			app.readyChecks = tt.checks
+			app.draining.Store(tt.draining)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, _, body := ts.get(t, "/readyz")
+
+			var response struct {
+				Status string                 `json:"status"`
+				Checks map[string]checkResult `json:"checks"`
+			}
+			err := json.Unmarshal([]byte(body), &response)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, response.Status, tt.wantStatus)
+			assert.Equal(t, len(response.Checks), len(tt.wantChecks))
+			for name, status := range tt.wantChecks {
+				assert.Equal(t, response.Checks[name].Status, status)
+				assert.Equal(t, response.Checks[name].Latency != "", true)
+			}
+
+			if tt.wantLogged != "" {
+				assert.Equal(t, strings.Contains(logs.String(), tt.wantLogged), true)
+				assert.Equal(t, strings.Contains(body, tt.wantLogged), false)
+			}
+			assert.Equal(t, strings.Contains(body, `"error"`), false)
+		})
+	}
+}
+
+func TestCheckTemplates(t *testing.T) {
+	app := newTestApplication(t)
+	assert.Equal(t, app.checkTemplates(context.Background()), nil)
+
+	app.templateCache = nil
+	assert.Equal(t, app.checkTemplates(context.Background()) != nil, true)
+}
+
+// session store whose lookups never return
+type hangingStore struct {
+	*memstore.MemStore
+	release chan struct{}
+}
+
+func (s hangingStore) Find(token string) ([]byte, bool, error) {
+	<-s.release
+	return nil, false, nil
+}
+
+func TestCheckSessionStore(t *testing.T) {
+	err := checkSessionStore(memstore.New())(context.Background())
+	assert.Equal(t, err, nil)
+
+	store := hangingStore{MemStore: memstore.New(), release: make(chan struct{})}
+	defer close(store.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = checkSessionStore(store)(ctx)
+	assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+}
@@ -5,19 +5,41 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Danvs60/snippetbox/internal/models"
+	"github.com/Danvs60/snippetbox/internal/validator"
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
-// Server error helper writes error message and stack trace
-// and also sends generic server error (500) via response to user
-func (app *application) serverError(w http.ResponseWriter, err error) {
+// Server error helper logs error message and stack trace
+// and also sends generic server error (500) via response to user.
+// Queries past their deadline are a 503 instead, worth retrying later.
+// API requests get a problem+json body, see apiServerError
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	if isAPIRequest(r) {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	if errors.Is(err, models.ErrTimeout) {
+		// not a bug, no stack trace
+		app.logger.WarnContext(r.Context(), err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
 	// debug.Stack is stack trace of current goroutine
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
+	app.logger.ErrorContext(r.Context(), err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "trace", string(debug.Stack()))
 
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
@@ -33,25 +55,35 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
-func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+// requests to the JSON API, whose errors are problem+json
+// rather than plain text, including the ones of the middleware
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
 	// retrieve corresponding template set cached based on page
 	// raise error if page does not exist
 	ts, ok := app.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
 	// Initialise buffer to simulate response
 	buf := new(bytes.Buffer)
 
-	// exec template
+	// exec template, traced to tell slow pages from slow queries
+	_, span := otel.Tracer(tracerName).Start(r.Context(), "render "+page)
 	err := ts.ExecuteTemplate(buf, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		app.serverError(w, r, err)
 		return
 	}
+	span.End()
 
 	// write status out to response
 	w.WriteHeader(status)
@@ -66,6 +98,7 @@ func (app *application) newTemplateData(r *http.Request) *templateData {
 		CurrentYear:     time.Now().Year(),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
+		AuthenticatedID: app.authenticatedUserID(r),
 		CSRFToken:       nosurf.Token(r),
 	}
 }
@@ -103,3 +136,254 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// returns the ID of the authenticated user, or 0 if nobody is logged in
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// extract the :id route parameter (httprouter)
+// ok is false if it is not a positive integer
+func idParam(r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// canonical URL path of a snippet, e.g. "/s/Xk3pQ9rT2a"
+// suffix is appended as is, e.g. "/raw"
+func snippetPath(snippet *models.Snippet, suffix string) string {
+	return "/s/" + url.PathEscape(snippet.URLSlug()) + suffix
+}
+
+// returned by fetchOwnedSnippet when the snippet exists
+// but belongs to somebody else
+var errNotOwner = errors.New("snippet is not owned by the authenticated user")
+
+// fetch the snippet from the :id route parameter and check that it belongs
+// to the authenticated user. Returns models.ErrNoRecord or errNotOwner
+func (app *application) fetchOwnedSnippet(r *http.Request) (*models.Snippet, error) {
+	id, ok := idParam(r)
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		return nil, err
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		return nil, errNotOwner
+	}
+
+	return snippet, nil
+}
+
+// HTML wrapper for fetchOwnedSnippet. On failure the error response
+// is already written (404 or 403) and ok is false
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.fetchOwnedSnippet(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, errNotOwner):
+			app.clientError(w, http.StatusForbidden)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// field checks shared by every handler that creates or changes a snippet
+func validateSnippet(v *validator.Validator, title, content string) {
+	v.CheckField(validator.NotBlank(title), "title", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(content), "content", "This field cannot be blank")
+}
+
+// language must be one of the languages offered on the forms
+func validateLanguage(v *validator.Validator, language string) {
+	v.CheckField(validator.PermittedValue(language, languageValues()...), "language", "This field must be a supported language")
+}
+
+// visibility must be public, unlisted or private
+func validateVisibility(v *validator.Validator, visibility string) {
+	v.CheckField(validator.PermittedValue(visibility, models.AllVisibilities...), "visibility", "This field must equal public, unlisted or private")
+}
+
+// lowercase letters, digits and dashes, not starting with a dash
+var vanitySlugRX = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// vanity slugs are optional, an empty value removes it
+func validateVanitySlug(v *validator.Validator, vanitySlug string) {
+	if vanitySlug == "" {
+		return
+	}
+
+	v.CheckField(validator.MinChars(vanitySlug, 3), "vanity_slug", "This field must be at least 3 characters long")
+	v.CheckField(validator.MaxChars(vanitySlug, 50), "vanity_slug", "This field cannot be more than 50 characters long")
+	v.CheckField(validator.Matches(vanitySlug, vanitySlugRX), "vanity_slug", "This field can only contain lowercase letters, digits and dashes, and cannot start with a dash")
+}
+
+// expiry choices besides a number of days ("1", "7" or "365")
+const (
+	expiresNever  = "never"
+	expiresCustom = "custom"
+	// edit form only, leaves the expiry unchanged
+	expiresKeep = "keep"
+)
+
+// format of <input type='datetime-local'> values, read as UTC
+const expiresAtLayout = "2006-01-02T15:04"
+
+// Resolve an expiry choice to an expiry time, nil meaning never, for
+// both the HTML forms and the API. at is only used by the "custom" choice, and must be in the future and
+// within app.config.maxExpiry. Problems are added to v as field errors
+func (app *application) resolveExpiry(v *validator.Validator, choice string, at time.Time) *time.Time {
+	var expires time.Time
+	key := "expires"
+
+	switch choice {
+	case expiresNever:
+		// creating and editing snippets requires signing in,
+		// so anybody who gets here may keep a snippet forever
+		return nil
+	case expiresCustom:
+		expires = at.UTC()
+		key = "expires_at"
+	case "1", "7", "365":
+		days, _ := strconv.Atoi(choice)
+		expires = time.Now().UTC().AddDate(0, 0, days)
+	default:
+		v.AddFieldError("expires", "This field must equal 1, 7 or 365")
+		return nil
+	}
+
+	now := time.Now()
+	v.CheckField(expires.After(now), key, "This field must be in the future")
+	v.CheckField(!expires.After(now.Add(app.config.maxExpiry)), key, fmt.Sprintf("This field must be at most %d days from now", int(app.config.maxExpiry.Hours()/24)))
+
+	return &expires
+}
+
+// resolveExpiry for the expires and expires_at fields of the HTML forms
+func (app *application) readExpiryForm(v *validator.Validator, choice, at string) *time.Time {
+	var expiresAt time.Time
+
+	if choice == expiresCustom {
+		var err error
+		expiresAt, err = time.Parse(expiresAtLayout, at)
+		if err != nil {
+			v.AddFieldError("expires_at", "This field must be a valid date and time")
+			return nil
+		}
+	}
+
+	return app.resolveExpiry(v, choice, expiresAt)
+}
+
+// 0 means the snippet can be viewed any number of times
+func validateMaxViews(v *validator.Validator, maxViews int) {
+	v.CheckField(maxViews >= 0 && maxViews <= 100, "max_views", "This field must be between 0 and 100")
+}
+
+// read a string value from the query string, or the default if it is missing
+func readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// read an integer value from the query string, or the default if it is missing.
+// Records a field error on v if the value is not an integer
+func readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddFieldError(key, "must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
+
+// read page, size and sort from the query string and validate them.
+// The first value in sortSafelist is the default sort
+func readFilters(qs url.Values, defaultPageSize int, sortSafelist ...string) (models.Filters, *validator.Validator) {
+	v := &validator.Validator{}
+
+	filters := models.Filters{
+		Page:     readInt(qs, "page", 1, v),
+		PageSize: readInt(qs, "size", defaultPageSize, v),
+		Sort:     readString(qs, "sort", sortSafelist[0]),
+	}
+
+	v.CheckField(filters.Page > 0, "page", "must be greater than zero")
+	v.CheckField(filters.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.CheckField(filters.PageSize > 0, "size", "must be greater than zero")
+	v.CheckField(filters.PageSize <= 100, "size", "must be a maximum of 100")
+	v.CheckField(validator.PermittedValue(filters.Sort, sortSafelist...), "sort", "invalid sort value")
+
+	return filters, v
+}
+
+// report whether the request may act with scope.
+// Only token authenticated requests are limited by scopes
+func (app *application) hasScope(r *http.Request, scope string) bool {
+	token, ok := r.Context().Value(authenticatedTokenContextKey).(*models.Token)
+	if !ok {
+		return true
+	}
+
+	return token.HasScope(scope)
+}
+
+// write snippet content as plain text. The content is untrusted, so the
+// strictest CSP is used in case a browser ignores the content type
+func writeRawSnippet(w http.ResponseWriter, snippet *models.Snippet) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+
+	w.Write([]byte(snippet.Content))
+}
+
+// runs of characters that are not safe in a file name
+var unsafeFilenameRX = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// build a download file name from the snippet title and language,
+// e.g. "An old silent pond" -> "An-old-silent-pond.txt"
+func snippetFilename(snippet *models.Snippet) string {
+	name := strings.Trim(unsafeFilenameRX.ReplaceAllString(snippet.Title, "-"), "-.")
+	if name == "" {
+		name = "snippet-" + snippet.Slug
+	}
+
+	extension := ".txt"
+	if l, ok := findLanguage(snippet.Language); ok {
+		extension = l.Extension
+	}
+
+	return name + extension
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		language string
+		want     string
+	}{
+		{
+			name:  "ASCII title",
+			title: "An old silent pond",
+			want:  "An-old-silent-pond.txt",
+		},
+		{
+			name:     "Language extension",
+			title:    "main.go: hello world",
+			language: "go",
+			want:     "main.go-hello-world.go",
+		},
+		{
+			name:  "Partly non-ASCII title",
+			title: "Café au lait",
+			want:  "Caf-au-lait.txt",
+		},
+		{
+			// the slug rather than the sequential ID, which is never handed out
+			name:  "Non-ASCII title",
+			title: "古池や蛙飛び込む水の音",
+			want:  "snippet-Xk3pQ9rT2a.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippet := &models.Snippet{ID: 1, Slug: "Xk3pQ9rT2a", Title: tt.title, Language: tt.language}
+
+			assert.Equal(t, snippetFilename(snippet), tt.want)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// a language that can be chosen for a snippet
+// Value is the chroma lexer name stored in the database
+type language struct {
+	Value     string
+	Label     string
+	Extension string
+}
+
+// value stored when the language should be detected from the content
+const autoDetectLanguage = ""
+
+// languages offered on the create and edit forms, in display order
+var languages = []language{
+	{Value: autoDetectLanguage, Label: "Auto-detect", Extension: ".txt"},
+	{Value: "plaintext", Label: "Plain text", Extension: ".txt"},
+	{Value: "bash", Label: "Bash", Extension: ".sh"},
+	{Value: "c", Label: "C", Extension: ".c"},
+	{Value: "c++", Label: "C++", Extension: ".cpp"},
+	{Value: "css", Label: "CSS", Extension: ".css"},
+	{Value: "go", Label: "Go", Extension: ".go"},
+	{Value: "html", Label: "HTML", Extension: ".html"},
+	{Value: "java", Label: "Java", Extension: ".java"},
+	{Value: "javascript", Label: "JavaScript", Extension: ".js"},
+	{Value: "json", Label: "JSON", Extension: ".json"},
+	{Value: "markdown", Label: "Markdown", Extension: ".md"},
+	{Value: "python", Label: "Python", Extension: ".py"},
+	{Value: "ruby", Label: "Ruby", Extension: ".rb"},
+	{Value: "rust", Label: "Rust", Extension: ".rs"},
+	{Value: "sql", Label: "SQL", Extension: ".sql"},
+	{Value: "typescript", Label: "TypeScript", Extension: ".ts"},
+	{Value: "yaml", Label: "YAML", Extension: ".yaml"},
+}
+
+// values accepted by the language form field
+func languageValues() []string {
+	values := make([]string, len(languages))
+	for i, l := range languages {
+		values[i] = l.Value
+	}
+
+	return values
+}
+
+// look up a language by its stored value
+func findLanguage(value string) (language, bool) {
+	for _, l := range languages {
+		if l.Value == value {
+			return l, true
+		}
+	}
+
+	return language{}, false
+}
+
+// Output CSS classes rather than inline styles, inline styles would be
+// blocked by the CSP. The matching stylesheet is ui/static/css/chroma.css
+var htmlFormatter = html.New(html.WithClasses(true))
+
+// style used to generate ui/static/css/chroma.css
+var chromaStyle = styles.Get("github")
+
+// Render content as syntax highlighted HTML (a <pre class="chroma"> block).
+// Chroma escapes the content, so the result is safe to render as is
+func highlightCode(content, lang string) (template.HTML, error) {
+	var lexer chroma.Lexer
+	if lang == autoDetectLanguage {
+		lexer = lexers.Analyse(content)
+	} else {
+		lexer = lexers.Get(lang)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	// merge runs of tokens of the same type to keep the output small
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = htmlFormatter.Format(&buf, chromaStyle, iterator)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(buf.String()), nil
+}
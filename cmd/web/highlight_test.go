@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/ui"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+func TestLanguages(t *testing.T) {
+	// every selectable language must map to a chroma lexer
+	for _, l := range languages {
+		if l.Value == autoDetectLanguage {
+			continue
+		}
+
+		t.Run(l.Label, func(t *testing.T) {
+			assert.Equal(t, lexers.Get(l.Value) != nil, true)
+		})
+	}
+}
+
+func TestHighlightCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		want     string
+	}{
+		{
+			name:     "Go keyword",
+			content:  "package main",
+			language: "go",
+			want:     `<span class="kn">package</span>`,
+		},
+		{
+			name:     "Escapes content",
+			content:  "<script>alert(1)</script>",
+			language: "plaintext",
+			want:     "&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+		{
+			name:     "Auto-detect",
+			content:  "#!/bin/bash\necho hello",
+			language: autoDetectLanguage,
+			want:     `<span class="nb">echo</span>`,
+		},
+		{
+			name:     "Unknown language falls back to plain text",
+			content:  "some text",
+			language: "klingon",
+			want:     "some text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := highlightCode(tt.content, tt.language)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, strings.HasPrefix(string(got), `<pre class="chroma">`), true)
+			assert.Equal(t, strings.Contains(string(got), tt.want), true)
+			assert.Equal(t, strings.Contains(string(got), "style="), false)
+		})
+	}
+}
+
+func TestChromaStylesheet(t *testing.T) {
+	// ui/static/css/chroma.css must match the classes the formatter emits
+	// regenerate it with htmlFormatter.WriteCSS if chromaStyle changes
+	var buf bytes.Buffer
+	err := htmlFormatter.WriteCSS(&buf, chromaStyle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stylesheet, err := fs.ReadFile(ui.Files, "static/css/chroma.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, string(stylesheet), buf.String())
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// values of -log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Logger writing format records to w, with the ID of the request and
+// the ID of its trace attached to every record logged with its context
+func newLogger(w io.Writer, format string) *slog.Logger {
+	var h slog.Handler
+	if format == logFormatJSON {
+		h = slog.NewJSONHandler(w, nil)
+	} else {
+		h = slog.NewTextHandler(w, nil)
+	}
+
+	return slog.New(requestIDHandler{h})
+}
+
+// slog.Handler adding the request_id and trace_id attributes to records
+// logged with the context of a request, see requestID and traceRequest
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDContextKey).(string); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// IDs accepted from clients, anything else could break log lines
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Identify the request by the X-Request-ID header set by a proxy in front
+// of the server, or by a new random ID. The ID is echoed back in the
+// response and attached to every log line of the request
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// 128 random bits, hex encoded
+func newRequestID() string {
+	b := make([]byte, 16)
+	// never fails, see crypto/rand.Read
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
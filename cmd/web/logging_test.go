@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{name: "Accepted", header: "5f0c2a7e-1b2d-4c3e-9f00-aa11bb22cc33", wantSame: true},
+		{name: "Missing"},
+		{name: "Line break", header: "abc\nlevel=ERROR"},
+		{name: "Too long", header: strings.Repeat("a", 129)},
+	}
+
+	generatedRX := regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen, _ = r.Context().Value(requestIDContextKey).(string)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Request-ID", tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, r)
+
+			id := rr.Header().Get("X-Request-ID")
+			assert.Equal(t, id, seen)
+			if tt.wantSame {
+				assert.Equal(t, id, tt.header)
+			} else {
+				assert.Equal(t, generatedRX.MatchString(id), true)
+			}
+		})
+	}
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	var logs bytes.Buffer
+
+	app := newTestApplication(t)
+	app.logger = newLogger(&logs, logFormatJSON)
+	app.accessLog = newAccessLog(&logs, accessLogJSON, 1, "")
+
+	handler := requestID(app.logRequest(app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	}))))
+
+	r := httptest.NewRequest(http.MethodGet, "/snippet/create", nil)
+	r.Header.Set("X-Request-ID", "req-42")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+
+	// one JSON object per line: the panic, then the request
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+
+	records := make([]map[string]any, len(lines))
+	for i, line := range lines {
+		err := json.Unmarshal([]byte(line), &records[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, records[i]["request_id"], any("req-42"))
+	}
+
+	assert.Equal(t, records[0]["level"], any("ERROR"))
+	assert.Equal(t, records[0]["uri"], any("/snippet/create"))
+	assert.Equal(t, records[1]["msg"], any("request"))
+	assert.Equal(t, records[1]["status"], any(float64(500)))
+	trace, _ := records[0]["trace"].(string)
+	assert.Equal(t, strings.Contains(trace, "recoverPanic"), true)
+}
+
+func TestNewLoggerText(t *testing.T) {
+	var logs bytes.Buffer
+
+	logger := newLogger(&logs, logFormatText).With("component", "test")
+	logger.Info("hello")
+
+	assert.Equal(t, strings.Contains(logs.String(), `level=INFO msg=hello component=test`), true)
+	assert.Equal(t, strings.Contains(logs.String(), "request_id"), false)
+}
@@ -1,80 +1,174 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/Danvs60/snippetbox/internal/migrations"
 	"github.com/Danvs60/snippetbox/internal/models"
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 // Define an application struct to hold application-wide dependencies
 // This is for dependency injection to avoid using global variables
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
+	logger         *slog.Logger
+	accessLog      *accessLog
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	tokens         models.TokenModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	config         config
+	readyChecks    []readyCheck
+	metrics        *metrics
+	draining       atomic.Bool // set once shutdown begins, fails /readyz
 }
 
 func main() {
-	addr := flag.String("addr", ":4000", "HTTP Network Address")
-	dsn := flag.String("dsn", "web:st0ngb00ze@/snippetbox?parseTime=true", "MySQL data source name")
-	flag.Parse()
+	// settings from flags, SNIPPETBOX_* env vars and the config file
+	cfg, args, err := loadConfig(os.Args[1:], os.LookupEnv, os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		// the log format isn't known yet, one line per invalid setting
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		os.Exit(1)
+	}
+
+	// structured logs, see -log-format
+	logger := newLogger(os.Stdout, cfg.logFormat)
+
+	// log err and exit, a defer wouldn't run because of os.Exit
+	fatal := func(err error) {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// web [flags] migrate ... runs the command instead of the server
+	if len(args) > 0 && args[0] != "migrate" {
+		fatal(fmt.Errorf("unknown command %q", args[0]))
+	}
+
+	db, err := models.Open(cfg.dbDriver, cfg.dsn)
+	if err != nil {
+		fatal(err)
+	}
+	// NOTE: closed explicitly once the server has stopped,
+	// a defer wouldn't run because of os.Exit
 
-	// flags and local date and local time (joined by the bitwise OR |)
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	// include Lshortfile to include file name and line number of error
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	migrator, err := migrations.New(db, cfg.dbDriver, logger)
+	if err != nil {
+		fatal(err)
+	}
+
+	if len(args) > 0 {
+		err = runMigrate(migrator, args[1:], os.Stdout)
+		db.Close()
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
 
-	db, err := openDB(*dsn)
+	if cfg.autoMigrate {
+		err = migrator.Up()
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	// spans of requests and database calls, see -trace-exporter
+	shutdownTracing, err := setupTracing(context.Background(), cfg)
 	if err != nil {
-		errorLog.Fatal(err)
+		fatal(err)
 	}
-	defer db.Close()
 
 	// Template cache...
 	templateCache, err := newTemplateCache()
 	if err != nil {
-		errorLog.Fatal(err)
+		fatal(err)
 	}
 
 	// initialise decoder
 	formDecoder := form.NewDecoder()
 
 	// initialise new session manager
-	// uses MySQL as session store
-	// sessions expire in 12 hours (lifetime)
+	// sessions are stored in the database
+	// sessions expire after cfg.sessionLifetime (12 hours by default)
 	sessionManager := scs.New() // returns a pointer to the s.manager
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Store = newSessionStore(cfg.dbDriver, db)
+	sessionManager.Lifetime = cfg.sessionLifetime
 
 	// session cookie sent only over https
 	sessionManager.Cookie.Secure = true
 
 	// Initialise application var, the dependency container
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
+		logger:         logger,
+		accessLog:      newAccessLog(os.Stdout, cfg.accessLogFormat, cfg.accessLogSample, cfg.accessLogExclude),
+		snippets:       &models.SnippetModel{DB: db, Driver: cfg.dbDriver, QueryTimeout: cfg.queryTimeout},
+		users:          &models.UserModel{DB: db, Driver: cfg.dbDriver, BcryptCost: cfg.bcryptCost, QueryTimeout: cfg.queryTimeout},
+		tokens:         &models.TokenModel{DB: db, Driver: cfg.dbDriver, QueryTimeout: cfg.queryTimeout},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		config:         cfg,
+		metrics:        newMetrics(),
+	}
+	app.metrics.watchDB(db, cfg.dbDriver)
+
+	// count the requests whose session couldn't be loaded or saved
+	sessionManager.ErrorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		app.metrics.sessionErrors.Inc()
+		app.serverError(w, r, err)
 	}
 
+	// dependencies reported by /readyz
+	app.readyChecks = []readyCheck{
+		{name: "database", check: db.PingContext},
+		{name: "sessions", check: checkSessionStore(sessionManager.Store)},
+		{name: "templates", check: app.checkTemplates},
+	}
+
+	// delete expired snippets and sessions in the background
+	purger := &purger{
+		jobs: []purgeJob{
+			{name: "snippets", purge: app.snippets.PurgeExpired},
+			{name: "sessions", purge: (&models.SessionModel{DB: db, Driver: cfg.dbDriver, QueryTimeout: cfg.queryTimeout}).PurgeExpired},
+		},
+		interval:  cfg.purgeInterval,
+		grace:     cfg.purgeGrace,
+		batchSize: cfg.purgeBatch,
+		now:       time.Now,
+		logger:    logger,
+	}
+
+	// cancelled on SIGINT (Ctrl+C) or SIGTERM (e.g. sent on deploys),
+	// which stops the server and the background jobs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	purgeDone := purger.start(ctx)
+
 	// initialise tls.Config to hold non-default TLS settings
 	// allow only elliptic curves with assembly implementations
 	tlsConfig := &tls.Config{
@@ -83,26 +177,77 @@ func main() {
 
 	// initialise new http.Server to use custom logger
 	srv := &http.Server{
-		Addr:         *addr,
-		ErrorLog:     errorLog,
+		Addr:         cfg.addr,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		Handler:      app.routes(),
 		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.idleTimeout,
+		ReadTimeout:  cfg.readTimeout,
+		WriteTimeout: cfg.writeTimeout,
 	}
-	app.infoLog.Printf("Starting server on %s", srv.Addr)
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
-}
+	// /metrics on a listener of its own, kept off the public address
+	metricsErr := make(chan error, 1)
+	if cfg.metricsAddr != "" {
+		metricsSrv := &http.Server{
+			Addr:         cfg.metricsAddr,
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			Handler:      app.metricsRoutes(),
+			IdleTimeout:  cfg.idleTimeout,
+			ReadTimeout:  cfg.readTimeout,
+			WriteTimeout: cfg.writeTimeout,
+		}
+		go func() {
+			err := app.serve(ctx, metricsSrv, 0, cfg.shutdownTimeout, metricsSrv.ListenAndServe)
+			// the app server goes down with it
+			if err != nil {
+				stop()
+			}
+			metricsErr <- err
+		}()
+	} else {
+		metricsErr <- nil
+	}
+
+	err = app.serve(ctx, srv, cfg.drainDelay, cfg.shutdownTimeout, func() error {
+		return srv.ListenAndServeTLS(cfg.tlsCert, cfg.tlsKey)
+	})
+
+	// also stops the background jobs when the server failed on its own
+	stop()
+	err = errors.Join(err, <-metricsErr)
+
+	// let the purger finish its current batch
+	logger.Info("waiting for background jobs")
+	<-purgeDone
+
+	logger.Info("closing database connections")
+	if closeErr := db.Close(); closeErr != nil {
+		logger.Error(closeErr.Error())
+	}
+
+	// send the spans still buffered
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	if traceErr := shutdownTracing(flushCtx); traceErr != nil {
+		logger.Error(traceErr.Error())
+	}
+	cancel()
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		return nil, err
+		fatal(err)
 	}
-	if err = db.Ping(); err != nil {
-		return nil, err
+
+	logger.Info("stopped")
+}
+
+// Session store of the database backend.
+// Expired sessions are deleted by the purger rather than by the store
+func newSessionStore(driver string, db *sql.DB) scs.Store {
+	switch driver {
+	case models.DriverSQLite:
+		return sqlite3store.NewWithCleanupInterval(db, 0)
+	case models.DriverPostgres:
+		return postgresstore.NewWithCleanupInterval(db, 0)
+	default:
+		return mysqlstore.NewWithCleanupInterval(db, 0)
 	}
-	return db, nil
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus collectors of the server, exposed on /metrics
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	snippetsCreated prometheus.Counter
+	logins          *prometheus.CounterVec
+	sessionErrors   prometheus.Counter
+}
+
+// Register the collectors in a registry of their own,
+// along with the Go runtime and process ones
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "snippetbox",
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route pattern and status code.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "snippetbox",
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		snippetsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "snippetbox",
+			Name:      "snippets_created_total",
+			Help:      "Snippets created, from the web form or the API.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "snippetbox",
+			Name:      "logins_total",
+			Help:      "Login attempts, by result.",
+		}, []string{"result"}),
+		sessionErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "snippetbox",
+			Name:      "session_store_errors_total",
+			Help:      "Failures to load or save a session in the session store.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.snippetsCreated,
+		m.logins,
+		m.sessionErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Also report the connection pool stats of db, labelled with its name
+func (m *metrics) watchDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// the /metrics endpoint
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// route label of requests that matched no route, so that
+// scanners can't create a label value per path
+const unmatchedRoute = "unmatched"
+
+// Count requests and time them, labelled by the pattern of the route they
+// matched (e.g. /s/:slug) rather than by their path. The router fills
+// the pattern in, see patternRouter
+func (m *metrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		route := unmatchedRoute
+		ctx := context.WithValue(r.Context(), routePatternContextKey, &route)
+		rec := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status())).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// httprouter.Router recording the pattern of the matched route in the
+// request context, which httprouter doesn't expose on its own
+type patternRouter struct {
+	*httprouter.Router
+}
+
+func (pr patternRouter) Handler(method, path string, handler http.Handler) {
+	pr.Router.Handler(method, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routePatternContextKey).(*string); ok {
+			*route = path
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func (pr patternRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	pr.Handler(method, path, handler)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.get(t, "/ping")
+	ts.get(t, "/ping")
+	ts.get(t, "/s/abc")
+	ts.get(t, "/s/xyz")
+	ts.get(t, "/no/such/page")
+
+	tests := []struct {
+		name  string
+		route string
+		code  string
+		want  float64
+	}{
+		{name: "Static route", route: "/ping", code: "200", want: 2},
+		{name: "Route with parameter", route: "/s/:slug", code: "404", want: 2},
+		{name: "No route", route: unmatchedRoute, code: "404", want: 1},
+		{name: "Raw path", route: "/s/abc", code: "404", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := testutil.ToFloat64(app.metrics.requests.WithLabelValues(http.MethodGet, tt.route, tt.code))
+			assert.Equal(t, count, tt.want)
+		})
+	}
+
+	assert.Equal(t, testutil.CollectAndCount(app.metrics.requestDuration), 3)
+}
+
+func TestInstrumentPanic(t *testing.T) {
+	app := newTestApplication(t)
+
+	handler := app.metrics.instrument(app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	count := testutil.ToFloat64(app.metrics.requests.WithLabelValues(http.MethodGet, unmatchedRoute, "500"))
+	assert.Equal(t, count, float64(1))
+}
+
+func TestLoginMetrics(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "wrong")
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/user/login", form)
+
+	assert.Equal(t, testutil.ToFloat64(app.metrics.logins.WithLabelValues("success")), float64(1))
+	assert.Equal(t, testutil.ToFloat64(app.metrics.logins.WithLabelValues("failure")), float64(1))
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Run("Same listener", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.get(t, "/ping")
+		code, _, body := ts.get(t, "/metrics")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, strings.Contains(body, `snippetbox_http_requests_total{code="200",method="GET",route="/ping"} 1`), true)
+		assert.Equal(t, strings.Contains(body, "go_goroutines"), true)
+	})
+
+	t.Run("Separate listener", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.config.metricsAddr = ":9090"
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, "/metrics")
+		assert.Equal(t, code, http.StatusNotFound)
+
+		rr := httptest.NewRecorder()
+		app.metricsRoutes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Equal(t, strings.Contains(rr.Body.String(), "snippetbox_session_store_errors_total 0"), true)
+	})
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/Danvs60/snippetbox/internal/models"
 
 	"github.com/justinas/nosurf"
 )
@@ -22,11 +26,24 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// Write the completed request to the access log, with the status code,
+// the response size and the time taken. Skips excluded paths and the
+// requests left out by the sampling
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		if app.accessLog.excluded(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		next.ServeHTTP(w, r)
+		start := app.accessLog.now()
+		rec := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		if app.accessLog.sampled(rec.status()) {
+			app.accessLog.write(r, rec, start)
+		}
 	})
 }
 
@@ -40,7 +57,7 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				// or in HTTP/2 -> GOAWAY frame
 				w.Header().Set("Connection", "close")
 				// call server Error to feedback to user
-				app.serverError(w, fmt.Errorf("%s:", err))
+				app.serverError(w, r, fmt.Errorf("%s:", err))
 				// NOTE: why use fmt.Errorf -> recover returns an 'any' type
 				// so we need to 'normalise' it by formatting to an error type
 			}
@@ -64,6 +81,20 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// API counterpart of requireAuthentication
+// responds 401 with a problem body instead of redirecting to the login page
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiProblem(w, http.StatusUnauthorized, "you must be authenticated to access this resource", nil)
+			return
+		}
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Custom CSRF cookie middleware using NoSurf
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
@@ -86,9 +117,9 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		// check database
-		exists, err := app.users.Exists(id)
+		exists, err := app.users.Exists(r.Context(), id)
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 			return
 		}
 
@@ -96,9 +127,63 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			// matching user found
 			// make copy of context and append key:val to new copy
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
-			r  = r.WithContext(ctx)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authenticate API clients with an `Authorization: Bearer <token>` header.
+// Sets the same context values as authenticate, plus the token itself
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// tells caches the response depends on the Authorization header
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			next.ServeHTTP(w, r)
+			return
 		}
 
+		scheme, plaintext, ok := strings.Cut(authorizationHeader, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			app.invalidTokenResponse(w)
+			return
+		}
+
+		token, err := app.tokens.GetByPlaintext(r.Context(), plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.invalidTokenResponse(w)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, token.UserID)
+		ctx = context.WithValue(ctx, authenticatedTokenContextKey, token)
+		r = r.WithContext(ctx)
+
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Reject token authenticated requests whose token lacks scope.
+// Session authenticated requests are always allowed
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.hasScope(r, scope) {
+				app.apiProblem(w, http.StatusForbidden, fmt.Sprintf("your token is missing the %s scope", scope), nil)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Danvs60/snippetbox/internal/migrations"
+)
+
+var errMigrateUsage = errors.New("usage: web [flags] migrate up|down|status|to VERSION|adopt OWNER_ID")
+
+// Run the migrate command with the arguments following it,
+// e.g. [up] or [to 3]. The status table is written to w
+func runMigrate(m *migrations.Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return m.Up()
+
+	case args[0] == "down" && len(args) == 1:
+		return m.Down()
+
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return m.To(version)
+
+	// tables created by hand before migrations, see Migrator.Adopt
+	case args[0] == "adopt" && len(args) == 2:
+		ownerID, err := strconv.Atoi(args[1])
+		if err != nil || ownerID < 1 {
+			return fmt.Errorf("invalid owner id %q", args[1])
+		}
+		return m.Adopt(ownerID)
+
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied != nil {
+				applied = humanDate(*s.Applied)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+
+		return tw.Flush()
+
+	default:
+		return errMigrateUsage
+	}
+}
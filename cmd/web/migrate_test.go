@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/migrations"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+func TestRunMigrate(t *testing.T) {
+	db, err := models.Open(models.DriverSQLite, "file:"+filepath.Join(t.TempDir(), "snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := migrations.New(db, models.DriverSQLite, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    string
+		wantOutput []string
+	}{
+		{
+			name:    "No subcommand",
+			wantErr: errMigrateUsage.Error(),
+		},
+		{
+			name:    "Unknown subcommand",
+			args:    []string{"sideways"},
+			wantErr: errMigrateUsage.Error(),
+		},
+		{
+			name:    "Missing version",
+			args:    []string{"to"},
+			wantErr: errMigrateUsage.Error(),
+		},
+		{
+			name:    "Invalid version",
+			args:    []string{"to", "latest"},
+			wantErr: `invalid version "latest"`,
+		},
+		{
+			name:    "Invalid owner",
+			args:    []string{"adopt", "alice"},
+			wantErr: `invalid owner id "alice"`,
+		},
+		{
+			name: "To version",
+			args: []string{"to", "2"},
+		},
+		{
+			name:       "Status",
+			args:       []string{"status"},
+			wantOutput: []string{"VERSION  NAME", "0002     create_snippets", "0003     create_snippet_revisions  pending"},
+		},
+		{
+			name: "Up",
+			args: []string{"up"},
+		},
+		{
+			name: "Down",
+			args: []string{"down"},
+		},
+		{
+			name:       "Status after down",
+			args:       []string{"status"},
+			wantOutput: []string{"0004     create_tokens", "0005     create_sessions           pending"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := runMigrate(m, tt.args, &out)
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				assert.Equal(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, err, nil)
+			for _, want := range tt.wantOutput {
+				assert.Equal(t, strings.Contains(out.String(), want), true)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+// a table kept clean by the purger
+type purgeJob struct {
+	name  string
+	purge models.PurgeFunc
+}
+
+// Background worker deleting expired snippets and sessions.
+// Queries already filter expired rows out, without it they
+// would stay in the tables forever
+type purger struct {
+	jobs      []purgeJob
+	interval  time.Duration    // time between two runs
+	grace     time.Duration    // how long expired rows are kept
+	batchSize int              // rows deleted per statement, keeps locks short
+	now       func() time.Time // clock, replaced in tests
+	logger    *slog.Logger
+}
+
+// Run the purger in a new goroutine until ctx is cancelled.
+// The returned channel is closed once it has stopped
+func (p *purger) start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		p.run(ctx)
+	}()
+
+	return done
+}
+
+// Purge right away, then every interval until ctx is cancelled
+func (p *purger) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purgeOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Delete, in batches, every row that expired longer than the grace period
+// ago. Stops between batches once ctx is cancelled
+func (p *purger) purgeOnce(ctx context.Context) {
+	// there's no request to recover in, so a panic would
+	// take the whole server down with it
+	defer func() {
+		if err := recover(); err != nil {
+			p.logger.Error("purge panicked", "error", err)
+		}
+	}()
+
+	cutoff := p.now().Add(-p.grace)
+
+	for _, job := range p.jobs {
+		total, err := models.PurgeBatches(ctx, job.purge, cutoff, p.batchSize)
+		switch {
+		case err != nil:
+			// stopping on shutdown is not a failure
+			if ctx.Err() == nil {
+				p.logger.Error("purging expired rows", "table", job.name, "error", err)
+			}
+		// quiet when nothing expired, which is most runs
+		case total > 0:
+			p.logger.Info("purged expired rows", "table", job.name, "count", total)
+		}
+	}
+}
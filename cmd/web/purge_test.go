@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+)
+
+func TestPurgeOnce(t *testing.T) {
+	now := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	var logs bytes.Buffer
+
+	// 2 full batches then a short one
+	batches := []int{10, 10, 3}
+	var cutoffs []time.Time
+
+	p := &purger{
+		jobs: []purgeJob{
+			{name: "snippets", purge: func(ctx context.Context, before time.Time, limit int) (int, error) {
+				cutoffs = append(cutoffs, before)
+				n := batches[0]
+				batches = batches[1:]
+				return n, nil
+			}},
+			{name: "sessions", purge: func(ctx context.Context, before time.Time, limit int) (int, error) {
+				return 0, errors.New("connection refused")
+			}},
+		},
+		grace:     24 * time.Hour,
+		batchSize: 10,
+		now:       func() time.Time { return now },
+		logger:    slog.New(slog.NewTextHandler(&logs, nil)),
+	}
+
+	p.purgeOnce(context.Background())
+
+	assert.Equal(t, len(cutoffs), 3)
+	assert.Equal(t, cutoffs[0], now.Add(-24*time.Hour))
+	assert.Equal(t, strings.Contains(logs.String(), `level=INFO msg="purged expired rows" table=snippets count=23`), true)
+	assert.Equal(t, strings.Contains(logs.String(), `level=ERROR msg="purging expired rows" table=sessions error="connection refused"`), true)
+	// only the error for a failed job
+	assert.Equal(t, strings.Contains(logs.String(), `table=sessions count=`), false)
+}
+
+func TestPurgeOnceNothingExpired(t *testing.T) {
+	var logs bytes.Buffer
+
+	p := &purger{
+		jobs: []purgeJob{
+			{name: "snippets", purge: func(ctx context.Context, before time.Time, limit int) (int, error) {
+				return 0, nil
+			}},
+		},
+		batchSize: 10,
+		now:       time.Now,
+		logger:    slog.New(slog.NewTextHandler(&logs, nil)),
+	}
+
+	p.purgeOnce(context.Background())
+
+	assert.Equal(t, logs.String(), "")
+}
+
+func TestPurgeOnceCancelled(t *testing.T) {
+	calls := 0
+
+	p := &purger{
+		jobs: []purgeJob{
+			{name: "snippets", purge: func(ctx context.Context, before time.Time, limit int) (int, error) {
+				calls++
+				return limit, nil
+			}},
+		},
+		batchSize: 10,
+		now:       time.Now,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p.purgeOnce(ctx)
+
+	assert.Equal(t, calls, 0)
+}
+
+func TestPurgerStart(t *testing.T) {
+	purged := make(chan struct{}, 1)
+
+	p := &purger{
+		jobs: []purgeJob{
+			{name: "snippets", purge: func(ctx context.Context, before time.Time, limit int) (int, error) {
+				select {
+				case purged <- struct{}{}:
+				default:
+				}
+				return 0, nil
+			}},
+		},
+		interval:  time.Hour,
+		batchSize: 10,
+		now:       time.Now,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := p.start(ctx)
+
+	// the first purge runs right away, not after an interval
+	select {
+	case <-purged:
+	case <-time.After(time.Second):
+		t.Fatal("purger did not run on start")
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purger did not stop after cancel")
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/Danvs60/snippetbox/internal/models"
 	"github.com/Danvs60/snippetbox/ui"
 
 	"github.com/julienschmidt/httprouter"
@@ -12,13 +14,25 @@ import (
 // Define new servemux (the map between URL patterns and handlers),
 // then register the home function as the handler for '/'
 func (app *application) routes() http.Handler {
-	// use httprouter
-	router := httprouter.New()
+	// use httprouter, recording the matched route for the metrics
+	router := patternRouter{httprouter.New()}
 
-	// hook for custom exceptions
+	// hook for custom exceptions, problem+json for the API
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAPIRequest(r) {
+			app.apiProblem(w, http.StatusNotFound, "the requested resource could not be found", nil)
+			return
+		}
 		app.notFound(w)
 	})
+	// the Allow header is already set by httprouter
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAPIRequest(r) {
+			app.apiProblem(w, http.StatusMethodNotAllowed, fmt.Sprintf("the %s method is not supported for this resource", r.Method), nil)
+			return
+		}
+		app.clientError(w, http.StatusMethodNotAllowed)
+	})
 
 	// NOTE: create a File Server to serve static files
 	// here we want it to be a subtree path, so add a trailing /
@@ -29,11 +43,34 @@ func (app *application) routes() http.Handler {
 	// Ping route for testing
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
 
+	// probes for orchestrators and load balancers, without sessions
+	router.HandlerFunc(http.MethodGet, "/healthz", app.healthz)
+	router.HandlerFunc(http.MethodGet, "/readyz", app.readyz)
+
+	// unless they have a listener of their own, see metricsRoutes
+	if app.config.metricsAddr == "" {
+		router.Handler(http.MethodGet, "/metrics", app.metrics.handler())
+	}
+
 	// unprotected application routes
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	// each middleware is traced, the session is loaded from the database
+	dynamic := alice.New(traced("session", app.sessionManager.LoadAndSave), traced("csrf", noSurf), traced("authenticate", app.authenticate))
 
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
-	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/s/:slug", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodPost, "/s/:slug", dynamic.ThenFunc(app.snippetRevealPost))
+	router.Handler(http.MethodGet, "/s/:slug/history", dynamic.ThenFunc(app.snippetHistory))
+	router.Handler(http.MethodGet, "/s/:slug/diff", dynamic.ThenFunc(app.snippetDiff))
+	router.Handler(http.MethodGet, "/s/:slug/raw", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/s/:slug/download", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodGet, "/search", dynamic.ThenFunc(app.search))
+
+	// old numeric snippet URLs, kept for existing links
+	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.Then(app.redirectToSlug("")))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history", dynamic.Then(app.redirectToSlug("/history")))
+	router.Handler(http.MethodGet, "/snippet/view/:id/diff", dynamic.Then(app.redirectToSlug("/diff")))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.Then(app.redirectToSlug("/raw")))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.Then(app.redirectToSlug("/download")))
 
 	// user routes
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
@@ -47,11 +84,47 @@ func (app *application) routes() http.Handler {
 
 	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
 	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", protected.ThenFunc(app.snippetDeletePost))
+	router.Handler(http.MethodPost, "/snippet/rollback/:id", protected.ThenFunc(app.snippetRollbackPost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	router.Handler(http.MethodGet, "/account/snippets", protected.ThenFunc(app.accountSnippets))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens", protected.ThenFunc(app.accountTokensPost))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", protected.ThenFunc(app.accountTokenRevokePost))
+
+	// JSON API routes
+	// no 'noSurf' here: scripts can't fetch a CSRF token, instead readJSON
+	// only accepts application/json bodies, which cross-site forms can't send
+	// non-browser clients authenticate with a bearer token instead of the session
+	api := alice.New(traced("session", app.sessionManager.LoadAndSave), traced("authenticate", app.authenticate), traced("authenticate token", app.authenticateToken))
+	apiRead := api.Append(app.requireScope(models.ScopeSnippetsRead))
+
+	router.Handler(http.MethodGet, "/api/v1/snippets", apiRead.ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:slug", apiRead.ThenFunc(app.apiSnippetGet))
+
+	// PROTECTED API routes
+	apiWrite := api.Append(app.requireAPIAuthentication, app.requireScope(models.ScopeSnippetsWrite))
+
+	router.Handler(http.MethodPost, "/api/v1/snippets", apiWrite.ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodPut, "/api/v1/snippets/:slug", apiWrite.ThenFunc(app.apiSnippetUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:slug", apiWrite.ThenFunc(app.apiSnippetDelete))
 
 	// create standard chain of middleware (default)
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// requestID comes first so that every log line has the ID,
+	// then instrument, traceRequest and logRequest to time the
+	// whole chain and see the status code written by recoverPanic
+	standard := alice.New(requestID, app.metrics.instrument, app.traceRequest, app.logRequest, app.recoverPanic, secureHeaders)
 
 	// finally serve http map (mux)
 	return standard.Then(router)
 }
+
+// Handler of the separate metrics listener, see -metrics-addr
+func (app *application) metricsRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", app.metrics.handler())
+
+	return mux
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Run listenAndServe (e.g. srv.ListenAndServeTLS) until ctx is cancelled,
+// then shut srv down gracefully: fail /readyz for drainDelay while still
+// serving, stop accepting connections and wait up to timeout for in-flight
+// requests to finish.
+// Returns nil after a clean shutdown
+func (app *application) serve(ctx context.Context, srv *http.Server, drainDelay, timeout time.Duration, listenAndServe func() error) error {
+	shutdownErr := make(chan error, 1)
+
+	go func() {
+		<-ctx.Done()
+
+		app.draining.Store(true)
+		if drainDelay > 0 {
+			app.logger.Info("draining", "delay", drainDelay)
+			time.Sleep(drainDelay)
+		}
+
+		app.logger.Info("shutting down server", "addr", srv.Addr)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
+
+	app.logger.Info("starting server", "addr", srv.Addr)
+
+	// returns ErrServerClosed as soon as Shutdown is called,
+	// anything else means the server could not start or crashed
+	err := listenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// wait for the in-flight requests
+	err = <-shutdownErr
+	if err != nil {
+		return fmt.Errorf("shutting down server: %w", err)
+	}
+
+	app.logger.Info("stopped server", "addr", srv.Addr)
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+)
+
+// start app.serve on a random local port with a handler that blocks
+// until release is closed. Returns the server URL and serve's result
+func startBlockingServer(t *testing.T, ctx context.Context, timeout time.Duration, started chan<- struct{}, release <-chan struct{}) (string, <-chan error) {
+	app := newTestApplication(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte("OK"))
+		}),
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- app.serve(ctx, srv, 0, timeout, func() error {
+			return srv.Serve(ln)
+		})
+	}()
+
+	return "http://" + ln.Addr().String(), result
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	url, result := startBlockingServer(t, ctx, 5*time.Second, started, release)
+
+	// an in-flight request when the shutdown begins
+	code := make(chan int, 1)
+	go func() {
+		rs, err := http.Get(url)
+		if err != nil {
+			code <- 0
+			return
+		}
+		rs.Body.Close()
+		code <- rs.StatusCode
+	}()
+
+	<-started
+	cancel()
+
+	// serve must wait for the request
+	select {
+	case err := <-result:
+		t.Fatalf("serve returned before the in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	assert.Equal(t, <-code, http.StatusOK)
+	assert.Equal(t, <-result, nil)
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	url, result := startBlockingServer(t, ctx, 10*time.Millisecond, started, release)
+
+	go http.Get(url)
+
+	<-started
+	cancel()
+
+	err := <-result
+	assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+}
+
+func TestServeDrainDelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app := newTestApplication(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: app.routes()}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- app.serve(ctx, srv, 200*time.Millisecond, 5*time.Second, func() error {
+			return srv.Serve(ln)
+		})
+	}()
+
+	url := "http://" + ln.Addr().String() + "/readyz"
+
+	readyz := func() int {
+		rs, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		return rs.StatusCode
+	}
+
+	assert.Equal(t, readyz(), http.StatusOK)
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	// still serving, but no longer ready
+	assert.Equal(t, readyz(), http.StatusServiceUnavailable)
+	assert.Equal(t, <-result, nil)
+}
@@ -4,7 +4,12 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/Danvs60/snippetbox/internal/models"
 	"github.com/Danvs60/snippetbox/ui"
@@ -13,10 +18,24 @@ import (
 type templateData struct {
 	CurrentYear     int
 	Snippet         *models.Snippet
+	Highlighted     template.HTML
+	Revealed        bool
 	Snippets        []*models.Snippet
+	Filters         models.Filters
+	Metadata        models.Metadata
+	Query           string
+	Tokens          []*models.Token
+	NewToken        string
+	Scopes          []string
+	Languages       []language
+	Revisions       []*models.Revision
+	FromRevision    *models.Revision
+	ToRevision      *models.Revision
+	Diff            []diffLine
 	Form            any
 	Flash           string
 	IsAuthenticated bool
+	AuthenticatedID int
 	CSRFToken       string
 }
 
@@ -28,8 +47,79 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// number of bytes kept either side of the first match by highlight
+const highlightRadius = 80
+
+// Returns an excerpt of s around the first occurrence of any of the words
+// in query, with every occurrence wrapped in <mark>. The rest of the text
+// is HTML escaped, so the result is safe to render as is
+func highlight(s, query string) template.HTML {
+	terms := []string{}
+	for _, term := range strings.Fields(query) {
+		term = strings.TrimFunc(term, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if term != "" {
+			terms = append(terms, regexp.QuoteMeta(term))
+		}
+	}
+
+	var matches [][]int
+	if len(terms) > 0 {
+		rx := regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+		matches = rx.FindAllStringIndex(s, -1)
+	}
+
+	// cut the excerpt around the first match (or the start of s)
+	start, end := 0, len(s)
+	if len(matches) > 0 {
+		start = matches[0][0] - highlightRadius
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > start+2*highlightRadius {
+		end = start + 2*highlightRadius
+	}
+	// never split a multi-byte character
+	for start > 0 && !utf8.RuneStart(s[start]) {
+		start--
+	}
+	for end < len(s) && !utf8.RuneStart(s[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	pos := start
+	for _, m := range matches {
+		if m[0] < pos || m[1] > end {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(s[pos:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(s[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		pos = m[1]
+	}
+	b.WriteString(template.HTMLEscapeString(s[pos:end]))
+
+	if end < len(s) {
+		b.WriteString("…")
+	}
+
+	return template.HTML(b.String())
+}
+
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"highlight": highlight,
+	"join":      strings.Join,
+	"contains":  slices.Contains[[]string],
+	"sub":       func(a, b int) int { return a - b },
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
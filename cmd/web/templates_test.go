@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -38,3 +39,51 @@ func TestHumanDate(t *testing.T) {
 	}
 
 }
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("a", 100) + " needle " + strings.Repeat("b", 100)
+
+	tests := []struct {
+		name  string
+		s     string
+		query string
+		want  string
+	}{
+		{
+			name:  "Single match",
+			s:     "An old silent pond",
+			query: "silent",
+			want:  "An old <mark>silent</mark> pond",
+		},
+		{
+			name:  "Case insensitive, multiple terms",
+			s:     "An old silent pond",
+			query: "OLD pond",
+			want:  "An <mark>old</mark> silent <mark>pond</mark>",
+		},
+		{
+			name:  "Escapes content",
+			s:     "<b>bold</b> move",
+			query: "bold",
+			want:  "&lt;b&gt;<mark>bold</mark>&lt;/b&gt; move",
+		},
+		{
+			name:  "No match",
+			s:     "An old silent pond",
+			query: "frog",
+			want:  "An old silent pond",
+		},
+		{
+			name:  "Excerpt",
+			s:     long,
+			query: "needle",
+			want:  "…" + strings.Repeat("a", 79) + " <mark>needle</mark> " + strings.Repeat("b", 73) + "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(highlight(tt.s, tt.query)), tt.want)
+		})
+	}
+}
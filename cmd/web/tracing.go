@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// values of -trace-exporter
+const (
+	traceExporterNone   = "none"
+	traceExporterOTLP   = "otlp"   // OTLP over HTTP, see -trace-endpoint
+	traceExporterStdout = "stdout" // JSON, to stdout or -trace-file
+)
+
+var traceExporters = []string{traceExporterNone, traceExporterOTLP, traceExporterStdout}
+
+// name of the tracer of the web server spans
+const tracerName = "github.com/Danvs60/snippetbox/cmd/web"
+
+// Set the global tracer provider and propagator from the config. Spans
+// are only recorded when an exporter is set, and traces started by
+// clients are continued from their W3C traceparent header either way.
+// The returned function flushes the spans left and closes the exporter
+func setupTracing(ctx context.Context, cfg config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var file io.Closer
+
+	switch cfg.traceExporter {
+	case traceExporterOTLP:
+		// without an endpoint, OTEL_EXPORTER_OTLP_* env vars or localhost:4318
+		var opts []otlptracehttp.Option
+		if cfg.traceEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.traceEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+
+	case traceExporterStdout:
+		var w io.Writer = os.Stdout
+		if cfg.traceFile != "" {
+			f, openErr := os.OpenFile(cfg.traceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+			if openErr != nil {
+				return nil, openErr
+			}
+			w, file = f, f
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+
+	default:
+		// nothing to record, the global provider stays a no-op
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s trace exporter: %w", cfg.traceExporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("snippetbox"))),
+		// follow the sampling decision of the caller, if any
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.traceSample))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start the server span of a request, continuing the trace of the client
+// when it sent a traceparent header. The span is named after the pattern
+// of the matched route, so it must come after metrics.instrument
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		if id, ok := r.Context().Value(requestIDContextKey).(string); ok {
+			span.SetAttributes(attribute.String("request_id", id))
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if route, ok := r.Context().Value(routePatternContextKey).(*string); ok && *route != unmatchedRoute {
+			span.SetName(r.Method + " " + *route)
+			span.SetAttributes(semconv.HTTPRoute(*route))
+		}
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status()))
+		if rec.status() >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status()))
+		}
+	})
+}
+
+// Wrap a middleware in a span named name, covering the middleware
+// and everything after it in the chain
+func traced(name string, middleware func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := middleware(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := otel.Tracer(tracerName).Start(r.Context(), name)
+			defer span.End()
+
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
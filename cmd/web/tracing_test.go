@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record the spans of the test in memory, restoring the
+// global tracer provider and propagator when it ends
+func newSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+
+	oldProvider, oldPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(oldProvider)
+		otel.SetTextMapPropagator(oldPropagator)
+	})
+
+	return sr
+}
+
+// the ended span with the given name, fails the test if there is none
+func findSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	i := slices.IndexFunc(spans, func(s sdktrace.ReadOnlySpan) bool { return s.Name() == name })
+	if i < 0 {
+		t.Fatalf("no span named %q", name)
+	}
+
+	return spans[i]
+}
+
+func TestTraceRequest(t *testing.T) {
+	sr := newSpanRecorder(t)
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	header := http.Header{}
+	header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	code, _, _ := ts.do(t, http.MethodGet, "/s/Xk3pQ9rT2a", header, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	spans := sr.Ended()
+
+	server := findSpan(t, spans, "GET /s/:slug")
+	assert.Equal(t, server.SpanKind(), trace.SpanKindServer)
+	assert.Equal(t, server.SpanContext().TraceID().String(), traceID)
+	// continues the span of the client
+	assert.Equal(t, server.Parent().SpanID().String(), "00f067aa0ba902b7")
+	assert.Equal(t, server.Status().Code, codes.Unset)
+
+	// the middleware and the rendering are in the same trace
+	for _, name := range []string{"session", "csrf", "authenticate", "render view.tmpl"} {
+		span := findSpan(t, spans, name)
+		assert.Equal(t, span.SpanContext().TraceID().String(), traceID)
+	}
+
+	// render is nested in the middleware spans
+	render := findSpan(t, spans, "render view.tmpl")
+	authenticate := findSpan(t, spans, "authenticate")
+	assert.Equal(t, render.Parent().SpanID(), authenticate.SpanContext().SpanID())
+}
+
+func TestTraceRequestNewTrace(t *testing.T) {
+	sr := newSpanRecorder(t)
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/missing/page")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	spans := sr.Ended()
+
+	// unmatched requests are named after their method only
+	server := findSpan(t, spans, "GET")
+	assert.Equal(t, server.Parent().IsValid(), false)
+	assert.Equal(t, server.SpanContext().IsValid(), true)
+}
+
+func TestTraceRequestServerError(t *testing.T) {
+	sr := newSpanRecorder(t)
+
+	app := newTestApplication(t)
+	handler := app.traceRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	ts := newTestServer(t, handler)
+	defer ts.Close()
+
+	ts.get(t, "/")
+
+	server := findSpan(t, sr.Ended(), "GET")
+	assert.Equal(t, server.Status().Code, codes.Error)
+}
+
+func TestTraceIDLogged(t *testing.T) {
+	newSpanRecorder(t)
+
+	var logs bytes.Buffer
+	logger := newLogger(&logs, logFormatJSON)
+
+	ctx, span := otel.Tracer(tracerName).Start(context.Background(), "test")
+	logger.InfoContext(ctx, "hello")
+	span.End()
+
+	var record map[string]any
+	err := json.Unmarshal(logs.Bytes(), &record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, record["trace_id"], any(span.SpanContext().TraceID().String()))
+}
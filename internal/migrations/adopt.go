@@ -0,0 +1,198 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+// the tables created by hand before migrations existed, renamed
+// out of the way while the migrated schema is created
+var legacyTables = []string{"snippets", "users", "sessions"}
+
+// Bring a database created by hand, before migrations existed, under
+// migrations: the original snippets, users and sessions tables are renamed,
+// every migration is applied and their rows are copied over. Snippets keep
+// their IDs, so that the old numeric URLs redirect to them, and get a
+// random slug, a first revision and ownerID as owner, as they had none.
+// It all runs in one transaction on SQLite and PostgreSQL, so a failed
+// adoption leaves the tables as they were. Back the database up first,
+// MySQL can't roll back DDL and leaves them half adopted
+func (m *Migrator) Adopt(ownerID int) error {
+	return m.locked(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			return errors.New("migrations: the schema is already managed by migrations")
+		}
+
+		if !queryWorks(ctx, conn, "SELECT id FROM snippets WHERE 1 = 0") {
+			return errors.New("migrations: no hand-created snippets table to adopt")
+		}
+		if !isLegacy(ctx, conn) {
+			return errors.New("migrations: the snippets table already has the migrated schema")
+		}
+
+		var owners int
+		err = conn.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM users WHERE id = %d", ownerID)).Scan(&owners)
+		if err != nil {
+			return err
+		}
+		if owners == 0 {
+			return fmt.Errorf("migrations: no user %d to own the existing snippets", ownerID)
+		}
+
+		hasSessions := queryWorks(ctx, conn, "SELECT token FROM sessions WHERE 1 = 0")
+
+		err = m.transaction(ctx, conn, func(q execer) error {
+			return m.adopt(ctx, q, ownerID, hasSessions)
+		})
+		if err != nil {
+			return err
+		}
+		m.logger.Info("adopted hand-created schema", "owner", ownerID, "version", m.Latest())
+
+		return nil
+	})
+}
+
+// Rename the legacy tables, apply every migration and copy the rows over
+func (m *Migrator) adopt(ctx context.Context, q execer, ownerID int, hasSessions bool) error {
+	for _, table := range legacyTables {
+		if table == "sessions" && !hasSessions {
+			continue
+		}
+		_, err := q.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s RENAME TO legacy_%s", table, table))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := m.exec(ctx, q, m.backend.dropLegacyIndexes)
+	if err != nil {
+		return err
+	}
+
+	// not with apply, which commits each migration on its own
+	for _, migration := range m.migrations {
+		err = m.runOn(ctx, q, migration.up, m.backend.insert, migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("migrations: applying %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	err = m.copyLegacy(ctx, q, ownerID, hasSessions)
+	if err != nil {
+		return fmt.Errorf("migrations: copying the existing rows: %w", err)
+	}
+
+	return nil
+}
+
+// Copy the rows of the legacy tables into the migrated ones and drop them.
+// Only ints and generated slugs are formatted into the statements
+func (m *Migrator) copyLegacy(ctx context.Context, q execer, ownerID int, hasSessions bool) error {
+	stmts := []string{
+		`INSERT INTO users (id, name, email, hashed_password, created)
+		SELECT id, name, email, hashed_password, created FROM legacy_users`,
+		// unique placeholder slugs, replaced below
+		fmt.Sprintf(`INSERT INTO snippets (id, user_id, slug, title, content, created, expires)
+		SELECT id, %d, CONCAT('legacy-', id), title, content, created, expires FROM legacy_snippets`, ownerID),
+		`INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+		SELECT id, 1, user_id, title, content, created FROM snippets`,
+	}
+	if hasSessions {
+		stmts = append(stmts, `INSERT INTO sessions (token, data, expiry) SELECT token, data, expiry FROM legacy_sessions`)
+	}
+
+	for _, stmt := range stmts {
+		_, err := q.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT id FROM snippets")
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		slug, err := models.GenerateSlug()
+		if err != nil {
+			return err
+		}
+
+		// a collision fails on snippets_uc_slug, ~59 bits make it unlikely
+		_, err = q.ExecContext(ctx, fmt.Sprintf("UPDATE snippets SET slug = '%s' WHERE id = %d", slug, id))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.exec(ctx, q, m.backend.resetSequences)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range legacyTables {
+		if table == "sessions" && !hasSessions {
+			continue
+		}
+		_, err = q.ExecContext(ctx, "DROP TABLE legacy_"+table)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run the statements of script one at a time
+func (m *Migrator) exec(ctx context.Context, q execer, script string) error {
+	for _, stmt := range splitStatements(script) {
+		_, err := q.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// report whether the snippets table is the hand-created one,
+// without the columns added by the first migrations
+func isLegacy(ctx context.Context, conn *sql.Conn) bool {
+	return queryWorks(ctx, conn, "SELECT id, title, content, created, expires FROM snippets WHERE 1 = 0") &&
+		!queryWorks(ctx, conn, "SELECT slug FROM snippets WHERE 1 = 0")
+}
+
+// report whether query runs, i.e. its tables and columns exist
+func queryWorks(ctx context.Context, conn *sql.Conn, query string) bool {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return false
+	}
+	rows.Close()
+
+	return true
+}
@@ -0,0 +1,262 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// the schema of instances set up by hand, before migrations existed
+const legacySchema = `CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    hashed_password TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX users_uc_email ON users (email);
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+CREATE INDEX idx_snippets_created ON snippets (created);
+CREATE TABLE sessions (
+    token TEXT PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry REAL NOT NULL
+);
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+INSERT INTO users (name, email, hashed_password, created) VALUES
+    ('Alice', 'alice@example.com', '', '2024-01-01 10:00:00'),
+    ('Bob', 'bob@example.com', '', '2024-01-01 10:00:00');
+INSERT INTO snippets (title, content, created, expires) VALUES
+    ('An old silent pond', 'An old silent pond...', '2024-01-01 10:00:00', '2099-01-01 10:00:00'),
+    ('Over the wintry forest', 'Over the wintry forest...', '2024-01-01 10:00:00', '2099-01-01 10:00:00'),
+    ('First autumn morning', 'First autumn morning...', '2024-01-01 10:00:00', '2099-01-01 10:00:00');
+DELETE FROM snippets WHERE id = 2;
+INSERT INTO sessions (token, data, expiry) VALUES ('abc', x'00', 2460000.5);`
+
+// SQLite database with the hand-created tables
+func newLegacyDB(t *testing.T) *sql.DB {
+	db, err := models.Open(models.DriverSQLite, "file:"+filepath.Join(t.TempDir(), "snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(legacySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("pa$$word"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("UPDATE users SET hashed_password = ?", string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestAdopt(t *testing.T) {
+	db := newLegacyDB(t)
+
+	var infoLog bytes.Buffer
+
+	m, err := New(db, models.DriverSQLite, slog.New(slog.NewTextHandler(&infoLog, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first migration would fail on the existing tables
+	err = m.Up()
+	assert.Equal(t, strings.Contains(err.Error(), "migrate adopt OWNER_ID"), true)
+	assert.Equal(t, appliedCount(t, m), 0)
+
+	err = m.Adopt(2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, appliedCount(t, m), m.Latest())
+	assert.Equal(t, strings.Contains(infoLog.String(), "adopted hand-created schema"), true)
+
+	for _, table := range legacyTables {
+		assert.Equal(t, tableExists(t, db, "legacy_"+table), false)
+	}
+
+	ctx := context.Background()
+	snippets := &models.SnippetModel{DB: db, Driver: models.DriverSQLite}
+
+	// numeric IDs are kept, for the redirects of the old URLs
+	s, err := snippets.Get(ctx, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "First autumn morning")
+	assert.Equal(t, s.UserID, 2)
+	assert.Equal(t, s.Visibility, models.VisibilityPublic)
+	assert.Equal(t, len(s.Slug), 10)
+
+	// and the slug leads to the same snippet
+	bySlug, err := snippets.GetBySlug(ctx, s.Slug, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bySlug.ID, 3)
+
+	first, err := snippets.Get(ctx, 1, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, first.Slug != s.Slug, true)
+
+	revisions, err := snippets.Revisions(ctx, 3)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(revisions), 1)
+	assert.Equal(t, revisions[0].Author, "Bob")
+
+	// found by the full-text index
+	results, _, err := snippets.Search(ctx, "autumn", models.Filters{Page: 1, PageSize: 10})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(results), 1)
+
+	// users can still log in
+	users := &models.UserModel{DB: db, Driver: models.DriverSQLite, BcryptCost: 4}
+	id, err := users.Authenticate(ctx, "alice@example.com", "pa$$word")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, id, 1)
+
+	// new rows come after the copied ones
+	id, err = snippets.Insert(ctx, 1, "New", "New content", "", models.VisibilityPublic, nil, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, id, 4)
+
+	var sessions int
+	err = db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&sessions)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, sessions, 1)
+
+	// only once
+	err = m.Adopt(2)
+	assert.Equal(t, strings.Contains(err.Error(), "already managed by migrations"), true)
+}
+
+func TestAdoptErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, db *sql.DB, m *Migrator)
+		ownerID int
+		wantErr string
+	}{
+		{
+			name:    "Unknown owner",
+			setup:   func(t *testing.T, db *sql.DB, m *Migrator) {},
+			ownerID: 42,
+			wantErr: "no user 42 to own the existing snippets",
+		},
+		{
+			name: "Empty database",
+			setup: func(t *testing.T, db *sql.DB, m *Migrator) {
+				for _, table := range legacyTables {
+					if _, err := db.Exec("DROP TABLE " + table); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			ownerID: 1,
+			wantErr: "no hand-created snippets table to adopt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newLegacyDB(t)
+
+			m, err := New(db, models.DriverSQLite, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(t, db, m)
+
+			err = m.Adopt(tt.ownerID)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.Equal(t, strings.Contains(err.Error(), tt.wantErr), true)
+			// nothing was touched
+			assert.Equal(t, appliedCount(t, m), 0)
+		})
+	}
+}
+
+func TestAdoptRollback(t *testing.T) {
+	db := newLegacyDB(t)
+
+	m, err := New(db, models.DriverSQLite, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// copying the sessions fails, after the users and snippets
+	_, err = db.Exec("ALTER TABLE sessions DROP COLUMN data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Adopt(2)
+	assert.Equal(t, strings.Contains(err.Error(), "copying the existing rows"), true)
+
+	// the tables are left as they were
+	assert.Equal(t, appliedCount(t, m), 0)
+	for _, table := range legacyTables {
+		assert.Equal(t, tableExists(t, db, table), true)
+		assert.Equal(t, tableExists(t, db, "legacy_"+table), false)
+	}
+	assert.Equal(t, tableExists(t, db, "snippet_revisions"), false)
+
+	var snippets int
+	err = db.QueryRow("SELECT COUNT(*) FROM snippets").Scan(&snippets)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, snippets, 2)
+
+	// so the adoption can run again once the cause is fixed
+	_, err = db.Exec("ALTER TABLE sessions ADD COLUMN data BLOB NOT NULL DEFAULT x'00'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Adopt(2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, appliedCount(t, m), m.Latest())
+}
+
+func TestAdoptMigratedSchema(t *testing.T) {
+	db, err := models.Open(models.DriverSQLite, "file:"+filepath.Join(t.TempDir(), "snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db, models.DriverSQLite, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// created by the migrations, then the bookkeeping was lost
+	err = m.Up()
+	assert.Equal(t, err, nil)
+	_, err = db.Exec("DELETE FROM schema_migrations")
+	assert.Equal(t, err, nil)
+
+	err = m.Adopt(1)
+	assert.Equal(t, strings.Contains(err.Error(), "already has the migrated schema"), true)
+}
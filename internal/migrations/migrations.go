@@ -0,0 +1,447 @@
+// Package migrations creates and upgrades the database schema with the
+// versioned SQL files embedded from the directory of each backend.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// the down file reverting the up file. Applied versions are recorded in
+// the schema_migrations table
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+//go:embed mysql postgres sqlite
+var files embed.FS
+
+var fileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// A versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// A migration and when it was applied, nil if it's pending
+type Status struct {
+	Migration
+	Applied *time.Time
+}
+
+// The statements that differ between backends
+type backend struct {
+	createTable string
+	insert      string
+	delete      string
+	// lock held while migrating, so that instances started together
+	// don't apply the same migrations, empty when the backend allows
+	// a single writer anyway. Returns 1 once the lock is taken
+	lock   string
+	unlock string
+	// DDL commits implicitly, so migrations can't run in a transaction
+	// and the driver runs one statement at a time
+	noTransaction bool
+	// frees the index names of the tables renamed by Adopt, for
+	// the backends where index names are global, not per table
+	dropLegacyIndexes string
+	// moves the id sequences past the ids copied by Adopt
+	resetSequences string
+}
+
+var backends = map[string]backend{
+	models.DriverMySQL: {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied DATETIME NOT NULL
+		)`,
+		insert: `INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)`,
+		delete: `DELETE FROM schema_migrations WHERE version = ?`,
+		// returns 0 after waiting for 60 seconds
+		lock:          `SELECT GET_LOCK('snippetbox_migrations', 60)`,
+		unlock:        `SELECT RELEASE_LOCK('snippetbox_migrations')`,
+		noTransaction: true,
+	},
+	models.DriverSQLite: {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER NOT NULL PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied DATETIME NOT NULL
+		)`,
+		insert: `INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)`,
+		delete: `DELETE FROM schema_migrations WHERE version = ?`,
+		dropLegacyIndexes: `DROP INDEX IF EXISTS users_uc_email;
+			DROP INDEX IF EXISTS sessions_expiry_idx;`,
+	},
+	models.DriverPostgres: {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied TIMESTAMPTZ NOT NULL
+		)`,
+		insert: `INSERT INTO schema_migrations (version, name, applied) VALUES ($1, $2, $3)`,
+		delete: `DELETE FROM schema_migrations WHERE version = $1`,
+		// any number identifying the lock will do, this one spells "snip"
+		lock:   `SELECT 1 FROM (SELECT pg_advisory_lock(1936615792)) AS l`,
+		unlock: `SELECT pg_advisory_unlock(1936615792)`,
+		dropLegacyIndexes: `ALTER TABLE legacy_users DROP CONSTRAINT IF EXISTS users_uc_email;
+			DROP INDEX IF EXISTS sessions_expiry_idx;`,
+		resetSequences: `SELECT setval(pg_get_serial_sequence('users', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM users;
+			SELECT setval(pg_get_serial_sequence('snippets', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM snippets;
+			SELECT setval(pg_get_serial_sequence('snippet_revisions', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM snippet_revisions;`,
+	},
+}
+
+// a connection, or a transaction on it
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Applies and reverts the migrations of a backend
+type Migrator struct {
+	db         *sql.DB
+	backend    backend
+	migrations []Migration // sorted by version
+	logger     *slog.Logger
+}
+
+// Load the migrations of driver, logging every migration
+// applied or reverted to logger
+func New(db *sql.DB, driver string, logger *slog.Logger) (*Migrator, error) {
+	b, ok := backends[driver]
+	if !ok {
+		return nil, fmt.Errorf("migrations: unknown database driver %q", driver)
+	}
+
+	migrations, err := load(files, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, backend: b, migrations: migrations, logger: logger}, nil
+}
+
+// read the migrations in dir, every version needs both an up and a down file
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		matches := fileRX.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("migrations: unexpected file %s/%s", dir, entry.Name())
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migrations: invalid version in %s/%s", dir, entry.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+		if m.Name != matches[2] {
+			return nil, fmt.Errorf("migrations: version %d is both %s and %s", version, m.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			m.up = string(data)
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migrations: version %d of %s needs both an up and a down file", m.Version, dir)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest known version, the one Up migrates to
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Apply every pending migration
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Revert the latest applied migration, does nothing if none is applied
+func (m *Migrator) Down() error {
+	return m.migrate(func(applied map[int]time.Time) int {
+		// the version before the current one
+		current := 0
+		for version := range applied {
+			current = max(current, version)
+		}
+
+		target := 0
+		for _, migration := range m.migrations {
+			if migration.Version < current {
+				target = migration.Version
+			}
+		}
+
+		return target
+	})
+}
+
+// Apply or revert migrations until the schema is at version,
+// 0 reverts every migration
+func (m *Migrator) To(version int) error {
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("migrations: unknown version %d", version)
+	}
+
+	return m.migrate(func(map[int]time.Time) int {
+		return version
+	})
+}
+
+// List every migration, applied or not
+func (m *Migrator) Status() ([]Status, error) {
+	ctx := context.Background()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			statuses[i].Applied = &at
+		}
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) known(version int) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Migrate to the version returned by target, which is given the applied
+// versions. Runs on a single connection holding the migration lock
+func (m *Migrator) migrate(target func(applied map[int]time.Time) int) error {
+	return m.locked(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// the first migrations would fail on the existing tables
+		if len(applied) == 0 && isLegacy(ctx, conn) {
+			return errors.New("migrations: found tables created by hand, adopt them first with: migrate adopt OWNER_ID")
+		}
+
+		return m.apply(ctx, conn, applied, target(applied))
+	})
+}
+
+// Run fn on a single connection holding the migration lock
+func (m *Migrator) locked(fn func(ctx context.Context, conn *sql.Conn) error) (err error) {
+	ctx := context.Background()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.backend.lock != "" {
+		var locked sql.NullInt64
+		err = conn.QueryRowContext(ctx, m.backend.lock).Scan(&locked)
+		if err != nil {
+			return fmt.Errorf("migrations: locking: %w", err)
+		}
+		if locked.Int64 != 1 {
+			return errors.New("migrations: timed out waiting for another instance to finish migrating")
+		}
+		defer func() {
+			_, unlockErr := conn.ExecContext(ctx, m.backend.unlock)
+			err = errors.Join(err, unlockErr)
+		}()
+	}
+
+	return fn(ctx, conn)
+}
+
+// Apply or revert migrations on conn until the schema is at version
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, applied map[int]time.Time, version int) error {
+	// revert newest first
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+
+		err := m.run(ctx, conn, migration.down, m.backend.delete, migration.Version)
+		if err != nil {
+			return fmt.Errorf("migrations: reverting %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		m.logger.Info("reverted migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	// apply oldest first
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+
+		err := m.run(ctx, conn, migration.up, m.backend.insert, migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("migrations: applying %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		m.logger.Info("applied migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	return nil
+}
+
+// versions recorded in schema_migrations and when they were applied,
+// creates the table on first use
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	_, err := conn.ExecContext(ctx, m.backend.createTable)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = at
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Run a migration script and record it with the bookkeeping statement,
+// together in a transaction when the backend allows it
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script string, record string, args ...any) error {
+	return m.transaction(ctx, conn, func(q execer) error {
+		return m.runOn(ctx, q, script, record, args...)
+	})
+}
+
+// Run a migration script and record it with the bookkeeping statement on q
+func (m *Migrator) runOn(ctx context.Context, q execer, script string, record string, args ...any) error {
+	var err error
+	if m.backend.noTransaction {
+		err = m.exec(ctx, q, script)
+	} else {
+		_, err = q.ExecContext(ctx, script)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, record, args...)
+	return err
+}
+
+// Run fn in a transaction on conn, or straight on conn when the
+// backend can't run DDL in a transaction
+func (m *Migrator) transaction(ctx context.Context, conn *sql.Conn, fn func(q execer) error) error {
+	if m.backend.noTransaction {
+		return fn(conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// no-op once the transaction is committed
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Split a script into statements, each ending with a semicolon at the
+// end of a line
+func splitStatements(script string) []string {
+	var stmts []string
+
+	for _, stmt := range strings.Split(script, ";\n") {
+		stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	return stmts
+}
@@ -0,0 +1,181 @@
+package migrations
+
+import (
+	"bytes"
+	"database/sql"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+func TestLoadBackends(t *testing.T) {
+	mysql, err := load(files, models.DriverMySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every backend must end up with the same schema history
+	for _, driver := range models.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			migrations, err := load(files, driver)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, len(migrations), len(mysql))
+			for i := range min(len(migrations), len(mysql)) {
+				assert.Equal(t, migrations[i].Version, i+1)
+				assert.Equal(t, migrations[i].Name, mysql[i].Name)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		wantErr string
+	}{
+		{
+			name:    "Missing down file",
+			files:   []string{"0001_a.up.sql"},
+			wantErr: "version 1 of test needs both an up and a down file",
+		},
+		{
+			name:    "Unexpected file",
+			files:   []string{"0001_a.up.sql", "0001_a.down.sql", "README.md"},
+			wantErr: "unexpected file test/README.md",
+		},
+		{
+			name:    "Mismatched names",
+			files:   []string{"0001_a.up.sql", "0001_b.down.sql"},
+			wantErr: "version 1 is both",
+		},
+		{
+			name:    "Version 0",
+			files:   []string{"0000_a.up.sql", "0000_a.down.sql"},
+			wantErr: "invalid version in test/0000_a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, name := range tt.files {
+				fsys["test/"+name] = &fstest.MapFile{Data: []byte("SELECT 1;\n")}
+			}
+
+			_, err := load(fsys, "test")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.Equal(t, strings.Contains(err.Error(), tt.wantErr), true)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- the first one
+CREATE TABLE a (id INTEGER);
+
+CREATE INDEX a_idx ON a (id);
+`
+
+	stmts := splitStatements(script)
+
+	assert.Equal(t, len(stmts), 2)
+	assert.Equal(t, stmts[0], "-- the first one\nCREATE TABLE a (id INTEGER)")
+	assert.Equal(t, stmts[1], "CREATE INDEX a_idx ON a (id)")
+}
+
+// report whether table exists in a SQLite database
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	var exists bool
+
+	err := db.QueryRow(`SELECT EXISTS(SELECT true FROM sqlite_master WHERE type = 'table' AND name = ?)`, table).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
+// number of applied migrations
+func appliedCount(t *testing.T, m *Migrator) int {
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for _, s := range statuses {
+		if s.Applied != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	db, err := models.Open(models.DriverSQLite, "file:"+filepath.Join(t.TempDir(), "snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var infoLog bytes.Buffer
+
+	m, err := New(db, models.DriverSQLite, slog.New(slog.NewTextHandler(&infoLog, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest := m.Latest()
+
+	err = m.Up()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, appliedCount(t, m), latest)
+	assert.Equal(t, tableExists(t, db, "snippets"), true)
+	assert.Equal(t, strings.Contains(infoLog.String(), "msg=\"applied migration\" version=1 name=create_users"), true)
+
+	// nothing left to apply
+	infoLog.Reset()
+	err = m.Up()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, infoLog.String(), "")
+
+	err = m.Down()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, appliedCount(t, m), latest-1)
+	assert.Equal(t, tableExists(t, db, "sessions"), false)
+
+	err = m.To(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, appliedCount(t, m), 1)
+	assert.Equal(t, tableExists(t, db, "snippets"), false)
+	assert.Equal(t, tableExists(t, db, "users"), true)
+
+	err = m.To(0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, appliedCount(t, m), 0)
+	assert.Equal(t, tableExists(t, db, "users"), false)
+
+	// reverting nothing is fine
+	err = m.Down()
+	assert.Equal(t, err, nil)
+
+	err = m.To(latest + 1)
+	assert.Equal(t, err != nil, true)
+
+	// every down file leaves a clean slate for its up file
+	err = m.Up()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, appliedCount(t, m), latest)
+}
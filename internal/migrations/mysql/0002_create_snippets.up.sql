@@ -0,0 +1,22 @@
+-- slugs are case sensitive, hence the binary collation
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    slug VARCHAR(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+    vanity_slug VARCHAR(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(20) NOT NULL DEFAULT '',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    remaining_views INTEGER,
+    created DATETIME NOT NULL,
+    expires DATETIME,
+    CONSTRAINT snippets_uc_slug UNIQUE (slug),
+    CONSTRAINT snippets_uc_vanity_slug UNIQUE (vanity_slug),
+    CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FULLTEXT INDEX snippets_ft_title_content (title, content)
+);
+
+CREATE INDEX snippets_idx_created ON snippets (created);
+
+CREATE INDEX snippets_idx_expires ON snippets (expires);
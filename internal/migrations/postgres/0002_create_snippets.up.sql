@@ -0,0 +1,22 @@
+CREATE TABLE snippets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    slug VARCHAR(50) NOT NULL,
+    vanity_slug VARCHAR(50),
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(20) NOT NULL DEFAULT '',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    remaining_views INTEGER,
+    created TIMESTAMPTZ NOT NULL,
+    expires TIMESTAMPTZ,
+    CONSTRAINT snippets_uc_slug UNIQUE (slug),
+    CONSTRAINT snippets_uc_vanity_slug UNIQUE (vanity_slug)
+);
+
+CREATE INDEX snippets_idx_created ON snippets (created);
+
+CREATE INDEX snippets_idx_expires ON snippets (expires);
+
+-- same expression as the search queries, otherwise the index isn't used
+CREATE INDEX snippets_idx_search ON snippets USING GIN (to_tsvector('simple', title || ' ' || content));
@@ -0,0 +1,10 @@
+-- hash is the SHA-256 of the plaintext token
+CREATE TABLE tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hash BYTEA NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
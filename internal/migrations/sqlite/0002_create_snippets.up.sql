@@ -0,0 +1,38 @@
+-- times are stored as text in a sortable format, see models.Open
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    slug TEXT NOT NULL,
+    vanity_slug TEXT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT '',
+    visibility TEXT NOT NULL DEFAULT 'public',
+    remaining_views INTEGER,
+    created DATETIME NOT NULL,
+    expires DATETIME
+);
+
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets (slug);
+
+CREATE UNIQUE INDEX snippets_uc_vanity_slug ON snippets (vanity_slug);
+
+CREATE INDEX snippets_idx_created ON snippets (created);
+
+CREATE INDEX snippets_idx_expires ON snippets (expires);
+
+-- full-text index of title and content, kept in sync by the triggers
+CREATE VIRTUAL TABLE snippets_fts USING fts5 (title, content, content = 'snippets', content_rowid = 'id');
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    INSERT INTO snippets_fts (snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, content ON snippets BEGIN
+    INSERT INTO snippets_fts (snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO snippets_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
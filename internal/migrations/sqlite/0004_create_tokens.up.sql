@@ -0,0 +1,11 @@
+-- hash is the SHA-256 of the plaintext token
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    hash BLOB NOT NULL,
+    scopes TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX tokens_uc_hash ON tokens (hash);
@@ -0,0 +1,256 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Database backends the models can run on
+const (
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Drivers lists every supported backend
+var Drivers = []string{DriverMySQL, DriverSQLite, DriverPostgres}
+
+// The parts of the SQL that differ between backends.
+// Statements are written for MySQL, with ? placeholders,
+// and filled in or rewritten with the fields of a dialect
+type dialect struct {
+	// name of the database/sql driver
+	sqlDriver string
+	// expression for the current UTC time
+	now string
+	// appended to a SELECT to lock the rows until the end of the
+	// transaction, empty when the backend locks the whole database
+	forUpdate string
+	// placeholders are numbered, $1, $2...
+	numberedPlaceholders bool
+	// the id of a new row is read with RETURNING, LastInsertId isn't supported
+	returningID bool
+	// DELETE accepts ORDER BY and LIMIT
+	deleteLimit bool
+	// full-text condition on title and content, the rank of a match
+	// (higher is better), both taking the search terms as argument,
+	// and the conversion of a user query into search terms
+	match       string
+	rank        string
+	searchTerms func(query string) string
+	// report whether err is a unique constraint violation on key
+	isDuplicateKey func(err error, key string) bool
+}
+
+var mysqlDialect = &dialect{
+	sqlDriver:   "mysql",
+	now:         "UTC_TIMESTAMP()",
+	forUpdate:   " FOR UPDATE",
+	deleteLimit: true,
+	// relies on the FULLTEXT index on snippets (title, content)
+	match: "MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE)",
+	rank:  "MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE)",
+	searchTerms: func(query string) string {
+		return query
+	},
+	isDuplicateKey: func(err error, key string) bool {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, key)
+		}
+
+		return false
+	},
+}
+
+// columns of the unique keys, SQLite names the columns rather
+// than the key in constraint errors
+var sqliteUniqueKeys = map[string]string{
+	"users_uc_email":          "users.email",
+	"snippets_uc_slug":        "snippets.slug",
+	"snippets_uc_vanity_slug": "snippets.vanity_slug",
+}
+
+var sqliteDialect = &dialect{
+	sqlDriver: "sqlite",
+	// same format as the times written by the driver (see sqliteDSN),
+	// so that times compare as strings
+	now: "strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')",
+	// relies on the snippets_fts FTS5 table indexing snippets (title, content),
+	// bm25 ranks are negative, lower is better
+	match: "id IN (SELECT rowid FROM snippets_fts WHERE snippets_fts MATCH ?)",
+	rank:  "-(SELECT rank FROM snippets_fts WHERE snippets_fts MATCH ? AND rowid = snippets.id)",
+	// quote every word so that FTS5 operators are searched as text,
+	// any word matches like in MySQL natural language mode
+	searchTerms: func(query string) string {
+		terms := strings.Fields(query)
+		for i, term := range terms {
+			terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+
+		return strings.Join(terms, " OR ")
+	},
+	isDuplicateKey: func(err error, key string) bool {
+		var sqliteError *sqlite.Error
+		if errors.As(err, &sqliteError) {
+			return sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteError.Error(), sqliteUniqueKeys[key])
+		}
+
+		return false
+	},
+}
+
+var postgresDialect = &dialect{
+	sqlDriver:            "pgx",
+	now:                  "now()",
+	forUpdate:            " FOR UPDATE",
+	numberedPlaceholders: true,
+	returningID:          true,
+	// relies on the GIN index on the same expression
+	match: "to_tsvector('simple', title || ' ' || content) @@ to_tsquery('simple', ?)",
+	rank:  "ts_rank(to_tsvector('simple', title || ' ' || content), to_tsquery('simple', ?))",
+	// quote every word so that tsquery operators are searched as text,
+	// any word matches like in MySQL natural language mode
+	searchTerms: func(query string) string {
+		terms := strings.Fields(query)
+		for i, term := range terms {
+			term = strings.ReplaceAll(term, `\`, `\\`)
+			terms[i] = "'" + strings.ReplaceAll(term, "'", "''") + "'"
+		}
+
+		return strings.Join(terms, " | ")
+	},
+	isDuplicateKey: func(err error, key string) bool {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) {
+			return pgError.Code == "23505" && pgError.ConstraintName == key
+		}
+
+		return false
+	},
+}
+
+var dialects = map[string]*dialect{
+	DriverMySQL:    mysqlDialect,
+	DriverSQLite:   sqliteDialect,
+	DriverPostgres: postgresDialect,
+}
+
+// dialect of a backend, MySQL when driver is empty
+func dialectFor(driver string) *dialect {
+	if d, ok := dialects[driver]; ok {
+		return d
+	}
+
+	return mysqlDialect
+}
+
+// Rewrite the ? placeholders of stmt for the backend.
+// Statements must not contain ? anywhere else
+func (d *dialect) rebind(stmt string) string {
+	if !d.numberedPlaceholders {
+		return stmt
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range stmt {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Run an INSERT statement inside tx and return the id of the new row
+func (d *dialect) insert(ctx context.Context, tx *sql.Tx, stmt string, args ...any) (int, error) {
+	if d.returningID {
+		var id int
+		err := tx.QueryRowContext(ctx, d.rebind(stmt+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := tx.ExecContext(ctx, d.rebind(stmt), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// Statement deleting at most limit rows of table whose column is before a
+// cutoff, oldest first. cutoff is the SQL expression of the cutoff, the
+// statement takes its arguments followed by the limit
+func (d *dialect) purgeStmt(table, key, column, cutoff string) string {
+	if d.deleteLimit {
+		return d.rebind(fmt.Sprintf(`DELETE FROM %[1]s WHERE %[2]s < %[3]s ORDER BY %[2]s LIMIT ?`, table, column, cutoff))
+	}
+
+	return d.rebind(fmt.Sprintf(`DELETE FROM %[1]s WHERE %[2]s IN (SELECT %[2]s FROM %[1]s WHERE %[3]s < %[4]s ORDER BY %[3]s LIMIT ?)`, table, key, column, cutoff))
+}
+
+// Open a connection pool to the backend and check that it's reachable
+func Open(driver, dsn string) (*sql.DB, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("models: unknown database driver %q", driver)
+	}
+
+	if driver == DriverSQLite {
+		dsn = sqliteDSN(dsn)
+	}
+
+	db, err := sql.Open(d.sqlDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == DriverSQLite {
+		// SQLite allows a single writer at a time, more connections
+		// only trade waiting for "database is locked" errors
+		db.SetMaxOpenConns(1)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Add the settings the models rely on to a SQLite DSN: times are written
+// in a format that sorts as text, and foreign keys are enforced so that
+// revisions are deleted along with their snippet
+func sqliteDSN(dsn string) string {
+	name, query, _ := strings.Cut(dsn, "?")
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		// let the driver report it
+		return dsn
+	}
+
+	if !values.Has("_time_format") {
+		values.Set("_time_format", "sqlite")
+	}
+	values.Add("_pragma", "foreign_keys(1)")
+
+	return name + "?" + values.Encode()
+}
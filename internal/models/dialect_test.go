@@ -0,0 +1,240 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRebind(t *testing.T) {
+	const stmt = `UPDATE snippets SET title = ? WHERE id = ?`
+
+	tests := []struct {
+		name string
+		d    *dialect
+		want string
+	}{
+		{
+			name: "MySQL",
+			d:    mysqlDialect,
+			want: stmt,
+		},
+		{
+			name: "SQLite",
+			d:    sqliteDialect,
+			want: stmt,
+		},
+		{
+			name: "Postgres",
+			d:    postgresDialect,
+			want: `UPDATE snippets SET title = $1 WHERE id = $2`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.d.rebind(stmt), tt.want)
+		})
+	}
+}
+
+// a real unique constraint error of the SQLite driver,
+// which can't be built by hand
+func sqliteDuplicateError(t *testing.T) error {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (email TEXT NOT NULL UNIQUE)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`INSERT INTO users (email) VALUES ('alice@example.com'), ('alice@example.com')`)
+	if err == nil {
+		t.Fatal("expected a unique constraint error")
+	}
+
+	return err
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	mysqlErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice@example.com' for key 'users.users_uc_email'"}
+	postgresErr := &pgconn.PgError{Code: "23505", ConstraintName: "users_uc_email"}
+	sqliteErr := sqliteDuplicateError(t)
+
+	tests := []struct {
+		name string
+		d    *dialect
+		err  error
+		key  string
+		want bool
+	}{
+		{
+			name: "MySQL",
+			d:    mysqlDialect,
+			err:  mysqlErr,
+			key:  "users_uc_email",
+			want: true,
+		},
+		{
+			name: "MySQL wrapped",
+			d:    mysqlDialect,
+			err:  fmt.Errorf("insert: %w", mysqlErr),
+			key:  "users_uc_email",
+			want: true,
+		},
+		{
+			name: "MySQL other key",
+			d:    mysqlDialect,
+			err:  mysqlErr,
+			key:  "snippets_uc_slug",
+			want: false,
+		},
+		{
+			name: "MySQL other error",
+			d:    mysqlDialect,
+			err:  &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: users_uc_email"},
+			key:  "users_uc_email",
+			want: false,
+		},
+		{
+			name: "SQLite",
+			d:    sqliteDialect,
+			err:  sqliteErr,
+			key:  "users_uc_email",
+			want: true,
+		},
+		{
+			name: "SQLite other key",
+			d:    sqliteDialect,
+			err:  sqliteErr,
+			key:  "snippets_uc_slug",
+			want: false,
+		},
+		{
+			name: "Postgres",
+			d:    postgresDialect,
+			err:  postgresErr,
+			key:  "users_uc_email",
+			want: true,
+		},
+		{
+			name: "Postgres other key",
+			d:    postgresDialect,
+			err:  postgresErr,
+			key:  "snippets_uc_slug",
+			want: false,
+		},
+		{
+			name: "Postgres other error",
+			d:    postgresDialect,
+			err:  &pgconn.PgError{Code: "23503", ConstraintName: "users_uc_email"},
+			key:  "users_uc_email",
+			want: false,
+		},
+		{
+			// errors of another backend are never duplicates
+			name: "Wrong driver",
+			d:    postgresDialect,
+			err:  mysqlErr,
+			key:  "users_uc_email",
+			want: false,
+		},
+		{
+			name: "Plain error",
+			d:    mysqlDialect,
+			err:  errors.New("users_uc_email"),
+			key:  "users_uc_email",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.d.isDuplicateKey(tt.err, tt.key), tt.want)
+		})
+	}
+}
+
+func TestSqliteDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{
+			name: "Plain file",
+			dsn:  "file:snippetbox.db",
+			want: "file:snippetbox.db?_pragma=foreign_keys%281%29&_time_format=sqlite",
+		},
+		{
+			name: "Time format given",
+			dsn:  "file:snippetbox.db?_time_format=datetime",
+			want: "file:snippetbox.db?_pragma=foreign_keys%281%29&_time_format=datetime",
+		},
+		{
+			name: "Other pragma",
+			dsn:  "file:snippetbox.db?_pragma=busy_timeout(5000)",
+			want: "file:snippetbox.db?_pragma=busy_timeout%285000%29&_pragma=foreign_keys%281%29&_time_format=sqlite",
+		},
+		{
+			name: "Invalid query",
+			dsn:  "file:snippetbox.db?%zz",
+			want: "file:snippetbox.db?%zz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, sqliteDSN(tt.dsn), tt.want)
+		})
+	}
+}
+
+func TestPurgeStmt(t *testing.T) {
+	tests := []struct {
+		name   string
+		d      *dialect
+		cutoff string
+		want   string
+	}{
+		{
+			name:   "MySQL",
+			d:      mysqlDialect,
+			cutoff: "?",
+			want:   `DELETE FROM snippets WHERE expires < ? ORDER BY expires LIMIT ?`,
+		},
+		{
+			name:   "SQLite",
+			d:      sqliteDialect,
+			cutoff: "?",
+			want:   `DELETE FROM snippets WHERE id IN (SELECT id FROM snippets WHERE expires < ? ORDER BY expires LIMIT ?)`,
+		},
+		{
+			name:   "Postgres",
+			d:      postgresDialect,
+			cutoff: "?",
+			want:   `DELETE FROM snippets WHERE id IN (SELECT id FROM snippets WHERE expires < $1 ORDER BY expires LIMIT $2)`,
+		},
+		{
+			name:   "Cutoff expression",
+			d:      postgresDialect,
+			cutoff: postgresDialect.now,
+			want:   `DELETE FROM snippets WHERE id IN (SELECT id FROM snippets WHERE expires < now() ORDER BY expires LIMIT $1)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.d.purgeStmt("snippets", "id", "expires", tt.cutoff), tt.want)
+		})
+	}
+}
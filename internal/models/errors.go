@@ -8,4 +8,10 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	ErrDuplicateSlug = errors.New("models: duplicate slug")
+
+	// a query ran past its deadline, see QueryTimeout. Wraps
+	// context.DeadlineExceeded
+	ErrTimeout = errors.New("models: query timed out")
 )
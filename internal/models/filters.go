@@ -0,0 +1,67 @@
+package models
+
+// Filters holds the paging and sorting options for list queries
+type Filters struct {
+	Page     int
+	PageSize int
+	Sort     string
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// map the requested sort key to an ORDER BY clause
+// only keys in the safelist are ever interpolated into SQL,
+// anything else gets the fallback
+func (f Filters) orderBy(safelist map[string]string, fallback string) string {
+	if clause, ok := safelist[f.Sort]; ok {
+		return clause
+	}
+
+	return fallback
+}
+
+// Metadata describes the page of results returned by a list query,
+// all zero when there are no results
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
+}
+
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     (totalRecords + pageSize - 1) / pageSize,
+		TotalRecords: totalRecords,
+	}
+}
+
+func (m Metadata) HasPrev() bool {
+	return m.CurrentPage > 1
+}
+
+func (m Metadata) HasNext() bool {
+	return m.CurrentPage < m.LastPage
+}
+
+func (m Metadata) PrevPage() int {
+	return m.CurrentPage - 1
+}
+
+func (m Metadata) NextPage() int {
+	return m.CurrentPage + 1
+}
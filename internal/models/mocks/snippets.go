@@ -1,34 +1,216 @@
 package mocks
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Danvs60/snippetbox/internal/models"
 )
 
+var mockExpires = time.Now().AddDate(0, 0, 7)
+
+var mockRevisions = []*models.Revision{
+	{
+		ID:        2,
+		SnippetID: 1,
+		Number:    2,
+		UserID:    1,
+		Author:    "Alice",
+		Title:     "An old silent pond",
+		Content:   "An old silent pond...",
+		Created:   time.Now(),
+	},
+	{
+		ID:        1,
+		SnippetID: 1,
+		Number:    1,
+		UserID:    1,
+		Author:    "Alice",
+		Title:     "An old pond",
+		Content:   "An old pond...",
+		Created:   time.Now(),
+	},
+}
+
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	UserID:     1,
+	Slug:       "Xk3pQ9rT2a",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    &mockExpires,
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:         3,
+	UserID:     1,
+	Slug:       "Pr1vAt3zQx",
+	Title:      "Dear diary",
+	Content:    "Nobody else can read this",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Expires:    &mockExpires,
+}
+
+var mockUnlistedSnippet = &models.Snippet{
+	ID:         4,
+	UserID:     1,
+	Slug:       "uNl1st3dAb",
+	VanitySlug: "hidden-gem",
+	Title:      "Secret handshake",
+	Content:    "Only people with the link",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Created:    time.Now(),
+	Expires:    &mockExpires,
 }
 
+var mockBurnSnippet = &models.Snippet{
+	ID:             5,
+	UserID:         1,
+	Slug:           "bUrN4ft3rR",
+	Title:          "Temporary password",
+	Content:        "hunter2",
+	Language:       "plaintext",
+	Visibility:     models.VisibilityPublic,
+	RemainingViews: 1,
+	Created:        time.Now(),
+	Expires:        nil,
+}
+
+// returned for slug mockSlowSlug,
+// as by a database that didn't answer in time
+var errMockTimeout = fmt.Errorf("%w: %w", models.ErrTimeout, context.DeadlineExceeded)
+
+const mockSlowSlug = "sL0wQu3ry0"
+
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, language string, visibility string, expires *time.Time, maxViews int) (int, error) {
+	return 1, nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	switch id {
-	case 1:
+func (m *SnippetModel) Get(ctx context.Context, id int, viewerID int) (*models.Snippet, error) {
+	switch {
+	case id == 1:
 		return mockSnippet, nil
+	case id == 3 && viewerID == mockPrivateSnippet.UserID:
+		return mockPrivateSnippet, nil
+	case id == 4 && viewerID == mockUnlistedSnippet.UserID:
+		return mockUnlistedSnippet, nil
+	case id == 5 && viewerID == mockBurnSnippet.UserID:
+		return mockBurnSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string, viewerID int) (*models.Snippet, error) {
+	if slug == mockSlowSlug {
+		return nil, errMockTimeout
+	}
+
+	for _, s := range []*models.Snippet{mockSnippet, mockPrivateSnippet, mockUnlistedSnippet, mockBurnSnippet} {
+		if slug != s.Slug && slug != s.VanitySlug {
+			continue
+		}
+		if s.Visibility == models.VisibilityPrivate && viewerID != s.UserID {
+			break
+		}
+		return s, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) ConsumeView(ctx context.Context, slug string, viewerID int) (*models.Snippet, error) {
+	s, err := m.GetBySlug(ctx, slug, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// copy rather than modify the shared mock
+	consumed := *s
+	if consumed.ViewLimited() && consumed.UserID != viewerID {
+		consumed.RemainingViews--
+	}
+
+	return &consumed, nil
+}
+
+func (m *SnippetModel) Latest(ctx context.Context, filters models.Filters) ([]*models.Snippet, models.Metadata, error) {
+	return []*models.Snippet{mockSnippet}, models.Metadata{CurrentPage: 1, PageSize: filters.PageSize, FirstPage: 1, LastPage: 1, TotalRecords: 1}, nil
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, authorID int, title string, content string, language string, visibility string, vanitySlug string, expires *time.Time) error {
+	switch {
+	case vanitySlug == "taken":
+		return models.ErrDuplicateSlug
+	case id == 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) ListByUser(ctx context.Context, userID int, filters models.Filters) ([]*models.Snippet, models.Metadata, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, models.Metadata{CurrentPage: 1, PageSize: filters.PageSize, FirstPage: 1, LastPage: 1, TotalRecords: 1}, nil
+	default:
+		return []*models.Snippet{}, models.Metadata{}, nil
+	}
+}
+
+func (m *SnippetModel) Search(ctx context.Context, query string, filters models.Filters) ([]*models.Snippet, models.Metadata, error) {
+	if strings.Contains(strings.ToLower(mockSnippet.Content), strings.ToLower(query)) {
+		return []*models.Snippet{mockSnippet}, models.Metadata{CurrentPage: 1, PageSize: filters.PageSize, FirstPage: 1, LastPage: 1, TotalRecords: 1}, nil
+	}
+
+	return []*models.Snippet{}, models.Metadata{}, nil
+}
+
+func (m *SnippetModel) Revisions(ctx context.Context, snippetID int) ([]*models.Revision, error) {
+	switch snippetID {
+	case 1:
+		return mockRevisions, nil
+	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *SnippetModel) GetRevision(ctx context.Context, snippetID int, number int) (*models.Revision, error) {
+	if snippetID == 1 {
+		for _, r := range mockRevisions {
+			if r.Number == number {
+				return r, nil
+			}
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Rollback(ctx context.Context, snippetID int, number int, authorID int) error {
+	_, err := m.GetRevision(ctx, snippetID, number)
+	return err
 }
@@ -0,0 +1,59 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+var mockToken = &models.Token{
+	ID:      1,
+	UserID:  1,
+	Name:    "CI job",
+	Scopes:  []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite},
+	Created: time.Now(),
+}
+
+var mockReadOnlyToken = &models.Token{
+	ID:      2,
+	UserID:  1,
+	Name:    "Editor plugin",
+	Scopes:  []string{models.ScopeSnippetsRead},
+	Created: time.Now(),
+}
+
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(ctx context.Context, userID int, name string, scopes []string) (string, error) {
+	return "sbx_newtoken", nil
+}
+
+func (m *TokenModel) GetByPlaintext(ctx context.Context, plaintext string) (*models.Token, error) {
+	switch plaintext {
+	case "sbx_readwrite":
+		return mockToken, nil
+	case "sbx_readonly":
+		return mockReadOnlyToken, nil
+	case "sbx_slow":
+		return nil, errMockTimeout
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *TokenModel) ListByUser(ctx context.Context, userID int) ([]*models.Token, error) {
+	switch userID {
+	case 1:
+		return []*models.Token{mockToken, mockReadOnlyToken}, nil
+	default:
+		return []*models.Token{}, nil
+	}
+}
+
+func (m *TokenModel) Revoke(ctx context.Context, id int, userID int) error {
+	if userID == 1 && (id == 1 || id == 2) {
+		return nil
+	}
+	return models.ErrNoRecord
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Deletes at most limit rows that expired before the cutoff and returns
+// how many were deleted, e.g. SnippetModel.PurgeExpired
+type PurgeFunc func(ctx context.Context, before time.Time, limit int) (int, error)
+
+// Call purge in batches of batchSize rows, which keeps the locks short,
+// until every row that expired before the cutoff is deleted.
+// Stops between batches once ctx is cancelled and returns ctx.Err(),
+// the batch in progress runs to completion. Returns how many rows were
+// deleted, also when it fails part way
+func PurgeBatches(ctx context.Context, purge PurgeFunc, before time.Time, batchSize int) (int, error) {
+	total := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := purge(context.WithoutCancel(ctx), before, batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		// a short batch means nothing is left
+		if n < batchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+func TestPurgeBatches(t *testing.T) {
+	cutoff := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+	errPurge := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		batches   []int // rows deleted by each call, an error once exhausted
+		cancelled bool
+		wantTotal int
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "Short last batch",
+			batches:   []int{10, 10, 3},
+			wantTotal: 23,
+			wantCalls: 3,
+		},
+		{
+			name:      "Full last batch",
+			batches:   []int{10, 10, 0},
+			wantTotal: 20,
+			wantCalls: 3,
+		},
+		{
+			name:      "Nothing expired",
+			batches:   []int{0},
+			wantCalls: 1,
+		},
+		{
+			name:      "Error part way",
+			batches:   []int{10},
+			wantTotal: 10,
+			wantCalls: 2,
+			wantErr:   errPurge,
+		},
+		{
+			name:      "Cancelled",
+			batches:   []int{10},
+			cancelled: true,
+			wantErr:   context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			calls := 0
+			purge := func(ctx context.Context, before time.Time, limit int) (int, error) {
+				assert.Equal(t, before, cutoff)
+				assert.Equal(t, limit, 10)
+
+				calls++
+				if calls > len(tt.batches) {
+					return 0, errPurge
+				}
+				return tt.batches[calls-1], nil
+			}
+
+			total, err := models.PurgeBatches(ctx, purge, cutoff, 10)
+			assert.Equal(t, total, tt.wantTotal)
+			assert.Equal(t, calls, tt.wantCalls)
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Revision type, ORM for snippet_revisions table.
+// Every change to the title or content of a snippet is kept as a
+// numbered revision, starting at 1 when the snippet is created
+type Revision struct {
+	ID        int
+	SnippetID int
+	Number    int
+	UserID    int
+	Author    string
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// Record a new revision inside tx, numbered after the latest one.
+// The snippet must be locked by tx, so that concurrent edits get
+// consecutive revision numbers
+func insertRevision(ctx context.Context, tx *sql.Tx, d *dialect, snippetID, authorID int, title, content string) error {
+	var number int
+
+	stmt := d.rebind(`SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`)
+
+	err := tx.QueryRowContext(ctx, stmt, snippetID).Scan(&number)
+	if err != nil {
+		return err
+	}
+
+	stmt = d.rebind(fmt.Sprintf(`INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+	VALUES (?, ?, ?, ?, ?, %s)`, d.now))
+
+	_, err = tx.ExecContext(ctx, stmt, snippetID, number, authorID, title, content)
+	return err
+}
+
+// Change title, content, language and visibility inside tx, recording a
+// revision if title or content changed. Returns ErrNoRecord if the snippet
+// does not exist
+func updateSnippet(ctx context.Context, tx *sql.Tx, d *dialect, id, authorID int, title, content, language, visibility string) error {
+	var currentTitle, currentContent string
+
+	// lock the row so concurrent edits get consecutive revision numbers
+	stmt := d.rebind(fmt.Sprintf(`SELECT title, content FROM snippets WHERE id = ?%s`, d.forUpdate))
+
+	err := tx.QueryRowContext(ctx, stmt, id).Scan(&currentTitle, &currentContent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	stmt = d.rebind(`UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ? WHERE id = ?`)
+
+	_, err = tx.ExecContext(ctx, stmt, title, content, language, visibility, id)
+	if err != nil {
+		return err
+	}
+
+	if title == currentTitle && content == currentContent {
+		return nil
+	}
+
+	return insertRevision(ctx, tx, d, id, authorID, title, content)
+}
+
+// List all revisions of a snippet, newest first
+func (m *SnippetModel) Revisions(ctx context.Context, snippetID int) (_ []*Revision, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Revisions", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(`SELECT r.id, r.snippet_id, r.revision, r.user_id, u.name, r.title, r.content, r.created
+	FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = ? ORDER BY r.revision DESC`)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+
+	for rows.Next() {
+		r := &Revision{}
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.Number, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Get a single revision of a snippet by its number
+func (m *SnippetModel) GetRevision(ctx context.Context, snippetID int, number int) (_ *Revision, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.GetRevision", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(`SELECT r.id, r.snippet_id, r.revision, r.user_id, u.name, r.title, r.content, r.created
+	FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = ? AND r.revision = ?`)
+
+	r := &Revision{}
+
+	err = m.DB.QueryRowContext(ctx, stmt, snippetID, number).Scan(&r.ID, &r.SnippetID, &r.Number, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Restore the title and content of an old revision.
+// History is never rewritten, the rollback is recorded as a new revision
+func (m *SnippetModel) Rollback(ctx context.Context, snippetID int, number int, authorID int) (err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Rollback", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// no-op once the transaction is committed
+	defer tx.Rollback()
+
+	d := m.dialect()
+
+	var title, content, language, visibility string
+
+	stmt := d.rebind(`SELECT r.title, r.content, s.language, s.visibility
+	FROM snippet_revisions r INNER JOIN snippets s ON s.id = r.snippet_id
+	WHERE r.snippet_id = ? AND r.revision = ?`)
+
+	err = tx.QueryRowContext(ctx, stmt, snippetID, number).Scan(&title, &content, &language, &visibility)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	err = updateSnippet(ctx, tx, d, snippetID, authorID, title, content, language, visibility)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Sessions are read and written by the scs store of the backend,
+// this model only takes care of deleting expired ones
+type SessionModel struct {
+	DB           *sql.DB
+	Driver       string        // backend of DB, MySQL if empty
+	QueryTimeout time.Duration // deadline of each statement, none if 0
+}
+
+func (m *SessionModel) dialect() *dialect {
+	return dialectFor(m.Driver)
+}
+
+// Delete at most limit sessions that expired before the cutoff,
+// oldest first. Returns the number of sessions deleted
+func (m *SessionModel) PurgeExpired(ctx context.Context, before time.Time, limit int) (_ int, err error) {
+	ctx, span := startSpan(ctx, "SessionModel.PurgeExpired", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	var stmt string
+	var cutoff any
+
+	if m.Driver == DriverSQLite {
+		// sqlite3store keeps the expiry as a Julian day number
+		stmt = m.dialect().purgeStmt("sessions", "token", "expiry", "julianday(?)")
+		cutoff = before.UTC().Format("2006-01-02T15:04:05.999")
+	} else {
+		stmt = m.dialect().purgeStmt("sessions", "token", "expiry", "?")
+		cutoff = before.UTC()
+	}
+
+	result, err := m.DB.ExecContext(ctx, stmt, cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"math/big"
+	"strings"
+)
+
+// characters used in generated slugs, all URL safe
+const slugAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// 10 characters out of 62 give ~59 bits of randomness,
+// far too many to enumerate
+const slugLength = 10
+
+// attempts at finding an unused slug before giving up
+const maxSlugAttempts = 5
+
+// Generate a random slug for a snippet, made of slugAlphabet only
+func GenerateSlug() (string, error) {
+	var b strings.Builder
+
+	max := big.NewInt(int64(len(slugAlphabet)))
+	for i := 0; i < slugLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(slugAlphabet[n.Int64()])
+	}
+
+	return b.String(), nil
+}
+
+// Report whether slug is already used by another snippet than id,
+// either as generated or as vanity slug
+func slugTaken(ctx context.Context, tx *sql.Tx, d *dialect, slug string, id int) (bool, error) {
+	var taken bool
+
+	stmt := d.rebind(`SELECT EXISTS(SELECT true FROM snippets WHERE (slug = ? OR vanity_slug = ?) AND id <> ?)`)
+
+	err := tx.QueryRowContext(ctx, stmt, slug, slug, id).Scan(&taken)
+	return taken, err
+}
+
+// Set the vanity slug chosen by the owner within tx, an empty slug removes it.
+// Returns ErrDuplicateSlug if the slug is used by another snippet
+func setVanitySlug(ctx context.Context, tx *sql.Tx, d *dialect, id int, vanitySlug string) error {
+	var value sql.NullString
+	if vanitySlug != "" {
+		taken, err := slugTaken(ctx, tx, d, vanitySlug, id)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateSlug
+		}
+
+		value = sql.NullString{String: vanitySlug, Valid: true}
+	}
+
+	stmt := d.rebind(`UPDATE snippets SET vanity_slug = ? WHERE id = ?`)
+
+	_, err := tx.ExecContext(ctx, stmt, value, id)
+	if err != nil {
+		// lost a race with another owner picking the same slug
+		if d.isDuplicateKey(err, "snippets_uc_vanity_slug") {
+			return ErrDuplicateSlug
+		}
+		return err
+	}
+
+	return nil
+}
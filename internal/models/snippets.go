@@ -1,73 +1,279 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
+// Who can see a snippet
+const (
+	// listed on the home page and in search results
+	VisibilityPublic = "public"
+	// only reachable by direct link
+	VisibilityUnlisted = "unlisted"
+	// only visible to its owner
+	VisibilityPrivate = "private"
+)
+
+// AllVisibilities lists every valid visibility, in display order
+var AllVisibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
 // Define a Snippet type mapping to the database
 // fields for snippets
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID             int        `json:"-"` // sequential, kept out of the API, see Slug
+	UserID         int        `json:"-"`
+	Slug           string     `json:"slug"`
+	VanitySlug     string     `json:"vanity_slug,omitempty"`
+	Title          string     `json:"title"`
+	Content        string     `json:"content"`
+	Language       string     `json:"language"`
+	Visibility     string     `json:"visibility"`
+	RemainingViews int        `json:"remaining_views,omitempty"` // 0 when views are unlimited
+	Created        time.Time  `json:"created"`
+	Expires        *time.Time `json:"expires"` // nil when the snippet never expires
+}
+
+// Slug to use in links: the vanity slug chosen by the owner, if any
+func (s *Snippet) URLSlug() string {
+	if s.VanitySlug != "" {
+		return s.VanitySlug
+	}
+
+	return s.Slug
 }
+
+// Report whether the snippet has expired, snippets without expiry never do
+func (s *Snippet) Expired() bool {
+	return s.Expires != nil && s.Expires.Before(time.Now())
+}
+
+// Report whether the snippet burns after a number of views
+func (s *Snippet) ViewLimited() bool {
+	return s.RemainingViews > 0
+}
+
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(ctx context.Context, userID int, title string, content string, language string, visibility string, expires *time.Time, maxViews int) (int, error)
+	Get(ctx context.Context, id int, viewerID int) (*Snippet, error)
+	GetBySlug(ctx context.Context, slug string, viewerID int) (*Snippet, error)
+	ConsumeView(ctx context.Context, slug string, viewerID int) (*Snippet, error)
+	Latest(ctx context.Context, filters Filters) ([]*Snippet, Metadata, error)
+	Update(ctx context.Context, id int, authorID int, title string, content string, language string, visibility string, vanitySlug string, expires *time.Time) error
+	Delete(ctx context.Context, id int) error
+	PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error)
+	ListByUser(ctx context.Context, userID int, filters Filters) ([]*Snippet, Metadata, error)
+	Search(ctx context.Context, query string, filters Filters) ([]*Snippet, Metadata, error)
+	Revisions(ctx context.Context, snippetID int) ([]*Revision, error)
+	GetRevision(ctx context.Context, snippetID int, number int) (*Revision, error)
+	Rollback(ctx context.Context, snippetID int, number int, authorID int) error
 }
 
 // Wrapper for a sql.DB connection pool
 type SnippetModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	Driver       string        // backend of DB, MySQL if empty
+	QueryTimeout time.Duration // deadline of the queries of each call, none if 0
+}
+
+func (m *SnippetModel) dialect() *dialect {
+	return dialectFor(m.Driver)
 }
 
 // Database commands
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// expires nil means the snippet never expires.
+// maxViews > 0 makes the snippet burn after that many views, 0 is unlimited
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, language string, visibility string, expires *time.Time, maxViews int) (_ int, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Insert", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	// the snippet and its first revision are inserted together
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	// no-op once the transaction is committed
+	defer tx.Rollback()
+
+	d := m.dialect()
+
 	// SQL statement to insert snippets.
 	// use backticks to define the string in multiple lines
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := fmt.Sprintf(`INSERT INTO snippets (user_id, slug, title, content, language, visibility, remaining_views, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, %s, ?)`, d.now)
+
+	// NULL means the snippet can be viewed any number of times
+	remainingViews := sql.NullInt64{Int64: int64(maxViews), Valid: maxViews > 0}
 
-	// Use exec method from embedded connection pool for
-	// non-query (not SELECT) statements
-	result, err := m.DB.Exec(stmt, title, content, expires)
-	// NOTE: can also ignore results
-	// _, err := ...
+	// NULL means the snippet never expires
+	expiresAt := nullTime(expires)
+
+	var id int
+
+	// retry with a new slug on the (unlikely) collision with an existing one
+	for attempt := 1; ; attempt++ {
+		slug, err := GenerateSlug()
+		if err != nil {
+			return 0, err
+		}
+
+		taken, err := slugTaken(ctx, tx, d, slug, 0)
+		if err != nil {
+			return 0, err
+		}
+
+		if !taken {
+			// a failed statement aborts the whole transaction in PostgreSQL,
+			// rolling back to the savepoint keeps it usable for the next attempt
+			_, err = tx.ExecContext(ctx, "SAVEPOINT insert_snippet")
+			if err != nil {
+				return 0, err
+			}
+
+			// WARNING: not all drivers support LastInsertId(),
+			// for example PostgreSQL, the dialect takes care of it
+			id, err = d.insert(ctx, tx, stmt, userID, slug, title, content, language, visibility, remainingViews, expiresAt)
+			if err == nil {
+				break
+			}
+			if !d.isDuplicateKey(err, "snippets_uc_slug") {
+				return 0, err
+			}
+
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT insert_snippet")
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		if attempt == maxSlugAttempts {
+			return 0, ErrDuplicateSlug
+		}
+	}
+
+	err = insertRevision(ctx, tx, d, id, userID, title, content)
 	if err != nil {
 		return 0, err
 	}
 
-	// get id of last inserted snippet
-	id, err := result.LastInsertId()
-	if err != nil {
+	if err = tx.Commit(); err != nil {
 		return 0, err
 	}
-	// could also use RowsAffected() to get number of rows affected
 
-	// WARNING: not all drivers support such result functions
-	// for example PostgreSQL does not support LastInsertId()
+	return id, nil
+}
+
+// Get a non-expired snippet by its numeric ID as seen by viewerID
+// (0 when anonymous). Only public snippets and the viewer's own are
+// returned, unlisted snippets are only reachable through their slug
+// and view-limited ones only through ConsumeView.
+// Hidden snippets are reported as ErrNoRecord, so their existence is
+// not revealed
+func (m *SnippetModel) Get(ctx context.Context, id int, viewerID int) (_ *Snippet, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Get", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	d := m.dialect()
+
+	stmt := d.rebind(fmt.Sprintf(`SELECT id, user_id, slug, COALESCE(vanity_slug, ''), title, content, language, visibility, COALESCE(remaining_views, 0), created, expires FROM snippets
+	WHERE (expires IS NULL OR expires > %s) AND id = ? AND ((visibility = 'public' AND remaining_views IS NULL) OR user_id = ?)`, d.now))
+
+	return scanSnippet(m.DB.QueryRowContext(ctx, stmt, id, viewerID))
+}
+
+// Get a non-expired snippet by its slug or vanity slug as seen by
+// viewerID (0 when anonymous). Private snippets of other users are
+// reported as ErrNoRecord.
+// Fetching a view-limited snippet does not count as a view, callers
+// must not show its content to anybody but the owner
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string, viewerID int) (_ *Snippet, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.GetBySlug", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	d := m.dialect()
+
+	stmt := d.rebind(fmt.Sprintf(`SELECT id, user_id, slug, COALESCE(vanity_slug, ''), title, content, language, visibility, COALESCE(remaining_views, 0), created, expires FROM snippets
+	WHERE (expires IS NULL OR expires > %s) AND (slug = ? OR vanity_slug = ?) AND (visibility <> 'private' OR user_id = ?)`, d.now))
 
-	// return the id, cast from int64 to int
-	return int(id), nil
+	return scanSnippet(m.DB.QueryRowContext(ctx, stmt, slug, slug, viewerID))
 }
 
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() and id = ?`
+// Like GetBySlug, but counts as one view of a view-limited snippet,
+// views by the owner are not counted.
+// The counter is decremented in a transaction holding the row lock, so
+// concurrent readers can't both get the last view. The snippet is
+// deleted once no views are left, and returned with RemainingViews 0
+func (m *SnippetModel) ConsumeView(ctx context.Context, slug string, viewerID int) (_ *Snippet, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.ConsumeView", m.Driver)
+	defer func() { endSpan(span, err) }()
 
-	row := m.DB.QueryRow(stmt, id)
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	d := m.dialect()
+
+	stmt := d.rebind(fmt.Sprintf(`SELECT id, user_id, slug, COALESCE(vanity_slug, ''), title, content, language, visibility, COALESCE(remaining_views, 0), created, expires FROM snippets
+	WHERE (expires IS NULL OR expires > %s) AND (slug = ? OR vanity_slug = ?) AND (visibility <> 'private' OR user_id = ?)%s`, d.now, d.forUpdate))
+
+	s, err := scanSnippet(tx.QueryRowContext(ctx, stmt, slug, slug, viewerID))
+	if err != nil {
+		return nil, err
+	}
+
+	// nothing to count
+	if !s.ViewLimited() || s.UserID == viewerID {
+		return s, nil
+	}
+
+	s.RemainingViews--
+	if s.RemainingViews == 0 {
+		// revisions go with it (ON DELETE CASCADE)
+		_, err = tx.ExecContext(ctx, d.rebind(`DELETE FROM snippets WHERE id = ?`), s.ID)
+	} else {
+		_, err = tx.ExecContext(ctx, d.rebind(`UPDATE snippets SET remaining_views = ? WHERE id = ?`), s.RemainingViews, s.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// scan a single snippet row
+func scanSnippet(row *sql.Row) (*Snippet, error) {
 	s := &Snippet{}
 
 	// scan only accepts pointers (mem. addresses) as input fields
 	// also the number of pointer parameters given to Scan
 	// will need to exactly match the number of columns given by the statement
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.UserID, &s.Slug, &s.VanitySlug, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.RemainingViews, &s.Created, &s.Expires)
 	// NOTE: this will take the query raw input and map it to Go standard types
 	// CHAR, VARCHAR and TEXT map to string
 	// BOOLEAN maps to bool
@@ -89,26 +295,40 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+// List non-expired public snippets that are not view-limited,
+// newest first, one page at a time
+func (m *SnippetModel) Latest(ctx context.Context, filters Filters) (_ []*Snippet, _ Metadata, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Latest", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
 
-	rows, err := m.DB.Query(stmt)
+	d := m.dialect()
+
+	// count(*) OVER() returns the total number of matching rows
+	// alongside every row, so one query gives both page and total
+	stmt := d.rebind(fmt.Sprintf(`SELECT count(*) OVER(), id, user_id, slug, COALESCE(vanity_slug, ''), title, content, language, visibility, COALESCE(remaining_views, 0), created, expires FROM snippets
+	WHERE (expires IS NULL OR expires > %s) AND visibility = 'public' AND remaining_views IS NULL ORDER BY id DESC LIMIT ? OFFSET ?`, d.now))
+
+	rows, err := m.DB.QueryContext(ctx, stmt, filters.limit(), filters.offset())
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 	// should defer *after* checking for an error coming from Query()
 	// otherwise, if Query returns an error, it will panic as you'd be
 	// trying to close a nil resultset
 	defer rows.Close()
 
+	totalRecords := 0
 	snippets := []*Snippet{}
 
 	for rows.Next() {
 		s := &Snippet{}
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&totalRecords, &s.ID, &s.UserID, &s.Slug, &s.VanitySlug, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.RemainingViews, &s.Created, &s.Expires)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
 
 		snippets = append(snippets, s)
@@ -116,9 +336,246 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	// check for errors during iteration
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
 	// all good
-	return snippets, nil
+	return snippets, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// sort keys accepted by ListByUser
+var userSnippetsOrderBy = map[string]string{
+	"created": "created DESC, id DESC",
+	// snippets that never expire come last
+	"expires": "expires IS NULL, expires ASC, id ASC",
+}
+
+// List all snippets created by a user, including expired ones
+func (m *SnippetModel) ListByUser(ctx context.Context, userID int, filters Filters) (_ []*Snippet, _ Metadata, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.ListByUser", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(fmt.Sprintf(`SELECT count(*) OVER(), id, user_id, slug, COALESCE(vanity_slug, ''), title, content, language, visibility, COALESCE(remaining_views, 0), created, expires FROM snippets
+	WHERE user_id = ? ORDER BY %s LIMIT ? OFFSET ?`, filters.orderBy(userSnippetsOrderBy, "created DESC, id DESC")))
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+		err = rows.Scan(&totalRecords, &s.ID, &s.UserID, &s.Slug, &s.VanitySlug, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.RemainingViews, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// List every snippet, including expired, private and view-limited ones,
+// newest first. Meant for administration, never shown to users
+func (m *SnippetModel) ListAll(ctx context.Context, filters Filters) (_ []*Snippet, _ Metadata, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.ListAll", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(`SELECT count(*) OVER(), id, user_id, slug, COALESCE(vanity_slug, ''), title, content, language, visibility, COALESCE(remaining_views, 0), created, expires FROM snippets
+	ORDER BY id DESC LIMIT ? OFFSET ?`)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+		err = rows.Scan(&totalRecords, &s.ID, &s.UserID, &s.Slug, &s.VanitySlug, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.RemainingViews, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Full-text search over title and content of non-expired public snippets,
+// best matches first. Relies on the full-text index of the backend
+// (see the dialects)
+func (m *SnippetModel) Search(ctx context.Context, query string, filters Filters) (_ []*Snippet, _ Metadata, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Search", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	d := m.dialect()
+
+	stmt := d.rebind(fmt.Sprintf(`SELECT count(*) OVER(), id, user_id, slug, COALESCE(vanity_slug, ''), title, content, language, visibility, COALESCE(remaining_views, 0), created, expires FROM snippets
+	WHERE (expires IS NULL OR expires > %s) AND visibility = 'public' AND remaining_views IS NULL AND %s
+	ORDER BY %s DESC, id DESC
+	LIMIT ? OFFSET ?`, d.now, d.match, d.rank))
+
+	terms := d.searchTerms(query)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, terms, terms, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+		err = rows.Scan(&totalRecords, &s.ID, &s.UserID, &s.Slug, &s.VanitySlug, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.RemainingViews, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Update title, content, language, visibility, vanity slug and expiry of an
+// existing snippet in one transaction, recording a new revision by authorID
+// if title or content changed. An empty vanity slug removes it and a nil
+// expiry means never. Returns ErrNoRecord if no snippet matches the id and
+// ErrDuplicateSlug if the vanity slug is used by another snippet
+func (m *SnippetModel) Update(ctx context.Context, id int, authorID int, title string, content string, language string, visibility string, vanitySlug string, expires *time.Time) (err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Update", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	d := m.dialect()
+
+	// locks the row, so the slug check below can't race another edit
+	err = updateSnippet(ctx, tx, d, id, authorID, title, content, language, visibility)
+	if err != nil {
+		return err
+	}
+
+	err = setVanitySlug(ctx, tx, d, id, vanitySlug)
+	if err != nil {
+		return err
+	}
+
+	stmt := d.rebind(`UPDATE snippets SET expires = ? WHERE id = ?`)
+
+	_, err = tx.ExecContext(ctx, stmt, nullTime(expires), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete at most limit snippets that expired before the cutoff, oldest
+// first, along with their revisions. Snippets without expiry are never
+// deleted. Returns the number of snippets deleted
+func (m *SnippetModel) PurgeExpired(ctx context.Context, before time.Time, limit int) (_ int, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.PurgeExpired", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().purgeStmt("snippets", "id", "expires", "?")
+
+	result, err := m.DB.ExecContext(ctx, stmt, before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// convert an optional time to a nullable UTC column value
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// Delete a snippet.
+// Returns ErrNoRecord if no snippet matches the id
+func (m *SnippetModel) Delete(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Delete", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(`DELETE FROM snippets WHERE id = ?`)
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// map a statement that touched no rows to ErrNoRecord
+func checkRowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
 }
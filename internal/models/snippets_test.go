@@ -0,0 +1,124 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+// snippet and user models on a fresh test database, with alice as user 1
+func newTestModels(t *testing.T) (*models.SnippetModel, *models.UserModel) {
+	db := newTestDB(t)
+	users := &models.UserModel{DB: db, Driver: models.DriverSQLite, BcryptCost: 4}
+	snippets := &models.SnippetModel{DB: db, Driver: models.DriverSQLite}
+
+	err := users.Insert(context.Background(), "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return snippets, users
+}
+
+func TestSnippetUpdate(t *testing.T) {
+	ctx := context.Background()
+	snippets, _ := newTestModels(t)
+
+	id, err := snippets.Insert(ctx, 1, "First", "content", "plaintext", "public", nil, 0)
+	assert.Equal(t, err, nil)
+
+	otherID, err := snippets.Insert(ctx, 1, "Second", "content", "plaintext", "public", nil, 0)
+	assert.Equal(t, err, nil)
+
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	err = snippets.Update(ctx, otherID, 1, "Second", "content", "plaintext", "public", "taken", nil)
+	assert.Equal(t, err, nil)
+
+	t.Run("Valid edit", func(t *testing.T) {
+		err := snippets.Update(ctx, id, 1, "Edited", "content", "go", "unlisted", "my-slug", &expires)
+		assert.Equal(t, err, nil)
+
+		s, err := snippets.Get(ctx, id, 1)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, s.Title, "Edited")
+		assert.Equal(t, s.Language, "go")
+		assert.Equal(t, s.Visibility, "unlisted")
+		assert.Equal(t, s.VanitySlug, "my-slug")
+		assert.Equal(t, s.Expires.Equal(expires), true)
+	})
+
+	t.Run("Duplicate slug", func(t *testing.T) {
+		err := snippets.Update(ctx, id, 1, "Lost", "lost", "python", "public", "taken", nil)
+		assert.Equal(t, errors.Is(err, models.ErrDuplicateSlug), true)
+
+		// nothing of the edit is applied
+		s, err := snippets.Get(ctx, id, 1)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, s.Title, "Edited")
+		assert.Equal(t, s.Content, "content")
+		assert.Equal(t, s.Language, "go")
+		assert.Equal(t, s.VanitySlug, "my-slug")
+		assert.Equal(t, s.Expires.Equal(expires), true)
+
+		revisions, err := snippets.Revisions(ctx, id)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(revisions), 2)
+	})
+
+	t.Run("Remove slug and expiry", func(t *testing.T) {
+		err := snippets.Update(ctx, id, 1, "Edited", "content", "go", "unlisted", "", nil)
+		assert.Equal(t, err, nil)
+
+		s, err := snippets.Get(ctx, id, 1)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, s.VanitySlug, "")
+		assert.Equal(t, s.Expires == nil, true)
+	})
+
+	t.Run("Non-existent", func(t *testing.T) {
+		err := snippets.Update(ctx, 42, 1, "Title", "content", "go", "public", "", nil)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	})
+}
+
+func TestSnippetConsumeView(t *testing.T) {
+	ctx := context.Background()
+	snippets, _ := newTestModels(t)
+
+	id, err := snippets.Insert(ctx, 1, "Burn after reading", "content", "plaintext", "public", nil, 2)
+	assert.Equal(t, err, nil)
+
+	s, err := snippets.Get(ctx, id, 1)
+	assert.Equal(t, err, nil)
+	slug := s.Slug
+
+	// the owner doesn't use up a view
+	s, err = snippets.ConsumeView(ctx, slug, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s.RemainingViews, 2)
+
+	s, err = snippets.GetBySlug(ctx, slug, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s.RemainingViews, 2)
+
+	s, err = snippets.ConsumeView(ctx, slug, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s.RemainingViews, 1)
+
+	// the last view deletes the snippet, but still shows it
+	s, err = snippets.ConsumeView(ctx, slug, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s.RemainingViews, 0)
+	assert.Equal(t, s.Title, "Burn after reading")
+
+	_, err = snippets.ConsumeView(ctx, slug, 0)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	_, err = snippets.GetBySlug(ctx, slug, 1)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
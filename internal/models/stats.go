@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Row counts giving an overview of an instance
+type Stats struct {
+	Users               int
+	Snippets            int
+	PublicSnippets      int
+	UnlistedSnippets    int
+	PrivateSnippets     int
+	ViewLimitedSnippets int
+	ExpiredSnippets     int // not purged yet
+	Revisions           int
+	Tokens              int
+	Sessions            int // including expired ones not purged yet
+}
+
+// Wrapper for db connection pool.
+type StatsModel struct {
+	DB           *sql.DB
+	Driver       string        // backend of DB, MySQL if empty
+	QueryTimeout time.Duration // deadline of the query, none if 0
+}
+
+// Count the rows of every table, in a single query
+func (m *StatsModel) Get(ctx context.Context) (_ *Stats, err error) {
+	ctx, span := startSpan(ctx, "StatsModel.Get", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := fmt.Sprintf(`SELECT
+		(SELECT count(*) FROM users),
+		(SELECT count(*) FROM snippets),
+		(SELECT count(*) FROM snippets WHERE visibility = 'public'),
+		(SELECT count(*) FROM snippets WHERE visibility = 'unlisted'),
+		(SELECT count(*) FROM snippets WHERE visibility = 'private'),
+		(SELECT count(*) FROM snippets WHERE remaining_views IS NOT NULL),
+		(SELECT count(*) FROM snippets WHERE expires <= %s),
+		(SELECT count(*) FROM snippet_revisions),
+		(SELECT count(*) FROM tokens),
+		(SELECT count(*) FROM sessions)`, dialectFor(m.Driver).now)
+
+	s := &Stats{}
+
+	err = m.DB.QueryRowContext(ctx, stmt).Scan(&s.Users, &s.Snippets, &s.PublicSnippets, &s.UnlistedSnippets, &s.PrivateSnippets,
+		&s.ViewLimitedSnippets, &s.ExpiredSnippets, &s.Revisions, &s.Tokens, &s.Sessions)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,32 @@
+package models_test
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/migrations"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+// migrated SQLite database in a temporary directory
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := models.Open(models.DriverSQLite, "file:"+filepath.Join(t.TempDir(), "snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.New(db, models.DriverSQLite, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Bound the queries of a model call by timeout, when positive,
+// on top of any deadline of ctx, e.g. the one of the request
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Wrap deadline errors in ErrTimeout, so callers can tell a slow
+// database apart from other failures. Other errors are returned as is.
+// Model methods defer it right after withTimeout, so it runs before
+// the deferred endSpan and the span records ErrTimeout too
+func timeoutError(err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrTimeout, err)
+}
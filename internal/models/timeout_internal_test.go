@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+)
+
+func TestTimeoutError(t *testing.T) {
+	wrapped := fmt.Errorf("%w: %w", ErrTimeout, context.DeadlineExceeded)
+
+	tests := []struct {
+		name        string
+		err         error
+		wantTimeout bool
+		wantErr     error // kept in the chain
+	}{
+		{
+			name: "Nil",
+		},
+		{
+			name:        "Deadline",
+			err:         context.DeadlineExceeded,
+			wantTimeout: true,
+			wantErr:     context.DeadlineExceeded,
+		},
+		{
+			name:        "Deadline from the driver",
+			err:         fmt.Errorf("read tcp: %w", context.DeadlineExceeded),
+			wantTimeout: true,
+			wantErr:     context.DeadlineExceeded,
+		},
+		{
+			name:    "Canceled",
+			err:     context.Canceled,
+			wantErr: context.Canceled,
+		},
+		{
+			name:    "Other error",
+			err:     ErrNoRecord,
+			wantErr: ErrNoRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := timeoutError(tt.err)
+			assert.Equal(t, errors.Is(err, ErrTimeout), tt.wantTimeout)
+			if tt.wantErr != nil {
+				assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			}
+			if !tt.wantTimeout {
+				assert.Equal(t, err, tt.err)
+			}
+		})
+	}
+
+	// wrapped only once
+	assert.Equal(t, timeoutError(wrapped), wrapped)
+}
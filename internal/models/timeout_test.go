@@ -0,0 +1,81 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		name         string
+		queryTimeout time.Duration
+		ctx          func() (context.Context, context.CancelFunc)
+		wantTimeout  bool
+		wantCanceled bool
+	}{
+		{
+			name: "No deadline",
+			ctx:  func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+		},
+		{
+			name:         "Query deadline",
+			queryTimeout: time.Nanosecond,
+			ctx:          func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+			wantTimeout:  true,
+		},
+		{
+			name:         "Caller deadline",
+			queryTimeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			},
+			wantTimeout: true,
+		},
+		{
+			// e.g. the client went away, not a slow database
+			name: "Caller canceled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantCanceled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			users := &models.UserModel{DB: db, Driver: models.DriverSQLite, QueryTimeout: tt.queryTimeout}
+			snippets := &models.SnippetModel{DB: db, Driver: models.DriverSQLite, QueryTimeout: tt.queryTimeout}
+			tokens := &models.TokenModel{DB: db, Driver: models.DriverSQLite, QueryTimeout: tt.queryTimeout}
+			stats := &models.StatsModel{DB: db, Driver: models.DriverSQLite, QueryTimeout: tt.queryTimeout}
+
+			_, err := users.Exists(ctx, 1)
+			assert.Equal(t, errors.Is(err, models.ErrTimeout), tt.wantTimeout)
+			assert.Equal(t, errors.Is(err, context.Canceled), tt.wantCanceled)
+
+			_, _, err = snippets.Latest(ctx, models.Filters{Page: 1, PageSize: 10})
+			assert.Equal(t, errors.Is(err, models.ErrTimeout), tt.wantTimeout)
+			// the context error is kept
+			assert.Equal(t, errors.Is(err, context.DeadlineExceeded), tt.wantTimeout)
+
+			_, err = tokens.GetByPlaintext(ctx, "sbx_unknown")
+			assert.Equal(t, errors.Is(err, models.ErrTimeout), tt.wantTimeout)
+			assert.Equal(t, errors.Is(err, context.Canceled), tt.wantCanceled)
+
+			_, err = stats.Get(ctx)
+			assert.Equal(t, errors.Is(err, models.ErrTimeout), tt.wantTimeout)
+			assert.Equal(t, errors.Is(err, context.Canceled), tt.wantCanceled)
+		})
+	}
+}
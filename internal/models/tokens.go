@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes that can be granted to an API token
+const (
+	ScopeSnippetsRead  = "snippets:read"
+	ScopeSnippetsWrite = "snippets:write"
+)
+
+// AllScopes lists every valid scope, in display order
+var AllScopes = []string{ScopeSnippetsRead, ScopeSnippetsWrite}
+
+// prefix of every plaintext token, makes leaked tokens easy to recognise
+const tokenPrefix = "sbx_"
+
+// Token type, ORM for tokens table.
+// Only the SHA-256 hash of the token is stored, the plaintext
+// is shown to the user once when the token is created
+type Token struct {
+	ID      int
+	UserID  int
+	Name    string
+	Scopes  []string
+	Created time.Time
+}
+
+// Report whether the token was granted scope
+func (t *Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+type TokenModelInterface interface {
+	Insert(ctx context.Context, userID int, name string, scopes []string) (string, error)
+	GetByPlaintext(ctx context.Context, plaintext string) (*Token, error)
+	ListByUser(ctx context.Context, userID int) ([]*Token, error)
+	Revoke(ctx context.Context, id int, userID int) error
+}
+
+// Wrapper for db connection pool.
+type TokenModel struct {
+	DB           *sql.DB
+	Driver       string        // backend of DB, MySQL if empty
+	QueryTimeout time.Duration // deadline of the queries of each call, none if 0
+}
+
+func (m *TokenModel) dialect() *dialect {
+	return dialectFor(m.Driver)
+}
+
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// Create a new token and return its plaintext value
+func (m *TokenModel) Insert(ctx context.Context, userID int, name string, scopes []string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "TokenModel.Insert", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	// 20 random bytes encode to 32 base32 characters without padding
+	randomBytes := make([]byte, 20)
+	_, err = rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	plaintext := tokenPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+
+	d := m.dialect()
+
+	stmt := d.rebind(fmt.Sprintf(`INSERT INTO tokens (user_id, name, hash, scopes, created)
+	VALUES (?, ?, ?, ?, %s)`, d.now))
+
+	// scopes are stored space separated, like OAuth scopes
+	_, err = m.DB.ExecContext(ctx, stmt, userID, name, hashToken(plaintext), strings.Join(scopes, " "))
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// Look up a token from the plaintext value sent by a client
+func (m *TokenModel) GetByPlaintext(ctx context.Context, plaintext string) (_ *Token, err error) {
+	ctx, span := startSpan(ctx, "TokenModel.GetByPlaintext", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(`SELECT id, user_id, name, scopes, created FROM tokens WHERE hash = ?`)
+
+	t := &Token{}
+	var scopes string
+
+	err = m.DB.QueryRowContext(ctx, stmt, hashToken(plaintext)).Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	t.Scopes = strings.Fields(scopes)
+
+	return t, nil
+}
+
+// List the tokens of a user, newest first
+func (m *TokenModel) ListByUser(ctx context.Context, userID int) (_ []*Token, err error) {
+	ctx, span := startSpan(ctx, "TokenModel.ListByUser", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(`SELECT id, user_id, name, scopes, created FROM tokens
+	WHERE user_id = ? ORDER BY id DESC`)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		t := &Token{}
+		var scopes string
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created)
+		if err != nil {
+			return nil, err
+		}
+		t.Scopes = strings.Fields(scopes)
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete a token. Users can only revoke their own tokens,
+// returns ErrNoRecord if the token does not exist or belongs to someone else
+func (m *TokenModel) Revoke(ctx context.Context, id int, userID int) (err error) {
+	ctx, span := startSpan(ctx, "TokenModel.Revoke", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(`DELETE FROM tokens WHERE id = ? AND user_id = ?`)
+
+	result, err := m.DB.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
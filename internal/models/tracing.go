@@ -0,0 +1,40 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/Danvs60/snippetbox/internal/models"
+
+// Start the span of a model method, a child of the span in ctx.
+// The tracer is looked up every time, so that spans go to the
+// provider set last with otel.SetTracerProvider
+func startSpan(ctx context.Context, name string, driver string) (context.Context, trace.Span) {
+	if driver == "" {
+		driver = DriverMySQL
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attribute.String("db.system", driver)))
+}
+
+// End the span of a model method that returned err. The errors callers
+// expect, like ErrNoRecord, are recorded without failing the span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+
+		expected := errors.Is(err, ErrNoRecord) || errors.Is(err, ErrInvalidCredentials) ||
+			errors.Is(err, ErrDuplicateEmail) || errors.Is(err, ErrDuplicateSlug)
+		if !expected {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+
+	span.End()
+}
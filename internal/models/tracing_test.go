@@ -0,0 +1,88 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestModelSpans(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+
+	oldProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(oldProvider) })
+
+	db := newTestDB(t)
+	users := &models.UserModel{DB: db, Driver: models.DriverSQLite, BcryptCost: 4}
+	snippets := &models.SnippetModel{DB: db, Driver: models.DriverSQLite}
+
+	// parent span, as started by a request
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "GET /s/:slug")
+
+	err := users.Insert(ctx, "Alice", "alice@example.com", "pa$$word")
+	assert.Equal(t, err, nil)
+
+	_, err = users.Exists(ctx, 1)
+	assert.Equal(t, err, nil)
+
+	_, err = snippets.Get(ctx, 42, 0)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	// table missing, a real failure
+	_, err = db.Exec("DROP TABLE snippet_revisions")
+	assert.Equal(t, err, nil)
+	_, err = snippets.Revisions(ctx, 1)
+	assert.Equal(t, err != nil, true)
+
+	parent.End()
+
+	spans := sr.Ended()
+
+	tests := []struct {
+		name       string
+		wantStatus codes.Code
+		wantEvents int
+	}{
+		{name: "UserModel.Insert", wantStatus: codes.Unset},
+		{name: "UserModel.Exists", wantStatus: codes.Unset},
+		// expected errors are recorded but don't fail the span
+		{name: "SnippetModel.Get", wantStatus: codes.Unset, wantEvents: 1},
+		{name: "SnippetModel.Revisions", wantStatus: codes.Error, wantEvents: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var span sdktrace.ReadOnlySpan
+			// the last span of that name
+			for _, s := range spans {
+				if s.Name() == tt.name {
+					span = s
+				}
+			}
+			if span == nil {
+				t.Fatalf("no span named %q", tt.name)
+			}
+
+			assert.Equal(t, span.Parent().SpanID(), parent.SpanContext().SpanID())
+			assert.Equal(t, span.Status().Code, tt.wantStatus)
+			assert.Equal(t, len(span.Events()), tt.wantEvents)
+
+			system := ""
+			for _, attr := range span.Attributes() {
+				if attr.Key == attribute.Key("db.system") {
+					system = attr.Value.AsString()
+				}
+			}
+			assert.Equal(t, system, models.DriverSQLite)
+		})
+	}
+}
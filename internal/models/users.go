@@ -1,12 +1,12 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"strings"
+	"fmt"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,33 +20,60 @@ type User struct {
 }
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
+	Insert(ctx context.Context, name, email, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
 }
 
+// bcrypt cost used when UserModel.BcryptCost is not set
+const DefaultBcryptCost = 12
+
 // Wrapper for db connection pool.
 type UserModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	Driver       string        // backend of DB, MySQL if empty
+	BcryptCost   int           // cost of new password hashes, DefaultBcryptCost if 0
+	QueryTimeout time.Duration // deadline of the queries of each call, none if 0
+}
+
+func (m *UserModel) dialect() *dialect {
+	return dialectFor(m.Driver)
+}
+
+// hash a new password with the configured cost
+func (m *UserModel) hashPassword(password string) ([]byte, error) {
+	cost := m.BcryptCost
+	if cost == 0 {
+		cost = DefaultBcryptCost
+	}
+
+	return bcrypt.GenerateFromPassword([]byte(password), cost)
 }
 
 // Insert new user record
-func (m *UserModel) Insert(name, email, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.Insert", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	hashedPassword, err := m.hashPassword(password)
 	if err != nil {
 		return err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES (?,?,?, UTC_TIMESTAMP())`
+	// the deadline starts after hashing, which can take a while
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	d := m.dialect()
 
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	stmt := d.rebind(fmt.Sprintf(`INSERT INTO users (name, email, hashed_password, created)
+	VALUES (?,?,?, %s)`, d.now))
+
+	_, err = m.DB.ExecContext(ctx, stmt, name, email, string(hashedPassword))
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		if d.isDuplicateKey(err, "users_uc_email") {
+			return ErrDuplicateEmail
 		}
 		return err
 	}
@@ -54,15 +81,47 @@ func (m *UserModel) Insert(name, email, password string) error {
 	return nil
 }
 
+// Replace the password of the user with the given email.
+// Returns ErrNoRecord if no user has that email
+func (m *UserModel) SetPassword(ctx context.Context, email, password string) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.SetPassword", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	hashedPassword, err := m.hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
+	stmt := m.dialect().rebind(`UPDATE users SET hashed_password = ? WHERE email = ?`)
+
+	result, err := m.DB.ExecContext(ctx, stmt, string(hashedPassword), email)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
 // Verify user exists and password matches
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (_ int, err error) {
+	ctx, span := startSpan(ctx, "UserModel.Authenticate", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
 	var id int
 	var hashedPassword []byte
 
-	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+	stmt := m.dialect().rebind("SELECT id, hashed_password FROM users WHERE email = ?")
 
 	// check if there exists a user with such email
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err = m.DB.QueryRowContext(ctx, stmt, email).Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -86,11 +145,18 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 }
 
 // Verify a user exists.
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "UserModel.Exists", m.Driver)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	defer func() { err = timeoutError(err) }()
+
 	var exists bool
 
-	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+	stmt := m.dialect().rebind("SELECT EXISTS(SELECT true FROM users WHERE id = ?)")
 
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&exists)
 	return exists, err
 }
@@ -0,0 +1,21 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Danvs60/snippetbox/internal/assert"
+	"github.com/Danvs60/snippetbox/internal/models"
+)
+
+func TestUserInsertDuplicateEmail(t *testing.T) {
+	_, users := newTestModels(t)
+
+	// alice@example.com is taken by newTestModels
+	err := users.Insert(context.Background(), "Alice Again", "alice@example.com", "pa$$word")
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+	err = users.Insert(context.Background(), "Bob", "bob@example.com", "pa$$word")
+	assert.Equal(t, err, nil)
+}
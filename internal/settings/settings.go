@@ -0,0 +1,108 @@
+// Package settings reads the settings of the binaries from command-line
+// flags, SNIPPETBOX_* environment variables and a JSON config file, so
+// that every setting can be given in any of the three ways
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefix of the environment variables read by Load
+const EnvPrefix = "SNIPPETBOX_"
+
+// Set the flags of fs from, in increasing order of precedence, their
+// defaults, the config file, the environment (read with lookupEnv) and
+// args. The flag -session-lifetime is SNIPPETBOX_SESSION_LIFETIME in
+// the environment and "session_lifetime" in the config file.
+// Load registers the -config flag naming the config file, which
+// defaults to SNIPPETBOX_CONFIG. Keys of the file without a flag are
+// rejected, unless ignoreUnknown: a binary with fewer settings can then
+// share the file of another
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool), ignoreUnknown bool) error {
+	configFile := fs.String("config", "", "JSON config file (default $"+EnvPrefix+"CONFIG)")
+
+	// the first pass only finds the config file,
+	// flag values are overwritten by the file and environment
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *configFile == "" {
+		*configFile, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+
+	if *configFile != "" {
+		err = applyFile(fs, *configFile, ignoreUnknown)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+
+		name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := lookupEnv(name); ok {
+			if err := f.Value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value %q", name, value))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// flags win over everything else
+	return fs.Parse(args)
+}
+
+// Set the flags named by the keys of a JSON object read from path.
+// Keys are setting names with underscores or dashes, unknown keys are
+// rejected so that typos don't go unnoticed
+func applyFile(fs *flag.FlagSet, path string, ignoreUnknown bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var settings map[string]json.RawMessage
+
+	err = json.Unmarshal(data, &settings)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var errs []error
+	for key, raw := range settings {
+		f := fs.Lookup(strings.ReplaceAll(key, "_", "-"))
+		if f == nil || f.Name == "config" {
+			if !ignoreUnknown {
+				errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, key))
+			}
+			continue
+		}
+
+		// strings are unquoted, numbers are used as written
+		value := string(bytes.TrimSpace(raw))
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			value = s
+		}
+
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: invalid value %s", path, key, raw))
+		}
+	}
+
+	return errors.Join(errs...)
+}